@@ -0,0 +1,215 @@
+package otools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/rudiarta/otools/outils"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxTagDepth limits how deep nested maps and structs are flattened into
+// dotted keys, deeper values are stored as a JSON string.
+const maxTagDepth = 5
+
+// tagAttributes converts a tag into span attributes using native OTel types
+// for bools, numbers and slices of them. Maps and structs are flattened into
+// "key.field" attributes, anything else falls back to outils.ToString. The
+// value is redacted with r first.
+func tagAttributes(r *outils.Redactor, key string, value interface{}) []attribute.KeyValue {
+	value = r.RedactKeyValue(key, value)
+	return appendAttributes(nil, key, value, 0)
+}
+
+// tagsAttributes converts every tag of every map with tagAttributes
+func tagsAttributes(r *outils.Redactor, tags ...map[string]interface{}) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, tag := range tags {
+		for k, v := range tag {
+			attrs = append(attrs, tagAttributes(r, k, v)...)
+		}
+	}
+	return attrs
+}
+
+func appendAttributes(attrs []attribute.KeyValue, key string, value interface{}, depth int) []attribute.KeyValue {
+	k := attribute.Key(key)
+
+	switch val := value.(type) {
+	case nil:
+		return append(attrs, k.String(outils.ToString(nil)))
+	case string:
+		return append(attrs, k.String(val))
+	case bool:
+		return append(attrs, k.Bool(val))
+	case []string:
+		return append(attrs, k.StringSlice(val))
+	case []bool:
+		return append(attrs, k.BoolSlice(val))
+	case []int:
+		return append(attrs, k.IntSlice(val))
+	case []int64:
+		return append(attrs, k.Int64Slice(val))
+	case []float64:
+		return append(attrs, k.Float64Slice(val))
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return append(attrs, k.Int64(i))
+		}
+		if f, err := val.Float64(); err == nil {
+			return append(attrs, k.Float64(f))
+		}
+		return append(attrs, k.String(val.String()))
+	case error, []byte, time.Time, time.Duration, fmt.Stringer, json.Marshaler:
+		return append(attrs, k.String(outils.ToString(val)))
+	}
+
+	rv := reflect.ValueOf(value)
+	if kv, ok := scalarAttribute(k, rv); ok {
+		return append(attrs, kv)
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return append(attrs, k.String(outils.ToString(nil)))
+		}
+		return appendAttributes(attrs, key, rv.Elem().Interface(), depth)
+	case reflect.Slice, reflect.Array:
+		if kv, ok := sliceAttribute(k, rv); ok {
+			return append(attrs, kv)
+		}
+	case reflect.Map:
+		if depth < maxTagDepth && rv.Type().Key().Kind() == reflect.String {
+			return appendMapAttributes(attrs, key, rv, depth)
+		}
+	case reflect.Struct:
+		if depth < maxTagDepth {
+			if m, ok := structToMap(value); ok {
+				return appendMapAttributes(attrs, key, reflect.ValueOf(m), depth)
+			}
+		}
+	}
+
+	return append(attrs, k.String(outils.ToString(value)))
+}
+
+func appendMapAttributes(attrs []attribute.KeyValue, key string, rv reflect.Value, depth int) []attribute.KeyValue {
+	keys := make([]string, 0, rv.Len())
+	for _, mk := range rv.MapKeys() {
+		keys = append(keys, mk.String())
+	}
+	sort.Strings(keys)
+
+	for _, sub := range keys {
+		v := rv.MapIndex(reflect.ValueOf(sub).Convert(rv.Type().Key()))
+		attrs = appendAttributes(attrs, key+"."+sub, v.Interface(), depth+1)
+	}
+	return attrs
+}
+
+func scalarAttribute(k attribute.Key, rv reflect.Value) (attribute.KeyValue, bool) {
+	switch rv.Kind() {
+	case reflect.String:
+		return k.String(rv.String()), true
+	case reflect.Bool:
+		return k.Bool(rv.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return k.Int64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return k.Int64(int64(u)), true
+		}
+		return k.String(outils.ToString(rv.Interface())), true
+	case reflect.Float32, reflect.Float64:
+		return k.Float64(rv.Float()), true
+	}
+	return attribute.KeyValue{}, false
+}
+
+// sliceAttribute converts slices whose elements are all of one supported
+// scalar kind into a typed slice attribute.
+func sliceAttribute(k attribute.Key, rv reflect.Value) (attribute.KeyValue, bool) {
+	n := rv.Len()
+	if n == 0 {
+		return attribute.KeyValue{}, false
+	}
+
+	first, ok := scalarAttribute(k, indirect(rv.Index(0)))
+	if !ok {
+		return attribute.KeyValue{}, false
+	}
+
+	switch first.Value.Type() {
+	case attribute.BOOL:
+		out := make([]bool, n)
+		for i := range out {
+			kv, ok := scalarAttribute(k, indirect(rv.Index(i)))
+			if !ok || kv.Value.Type() != attribute.BOOL {
+				return attribute.KeyValue{}, false
+			}
+			out[i] = kv.Value.AsBool()
+		}
+		return k.BoolSlice(out), true
+	case attribute.INT64:
+		out := make([]int64, n)
+		for i := range out {
+			kv, ok := scalarAttribute(k, indirect(rv.Index(i)))
+			if !ok || kv.Value.Type() != attribute.INT64 {
+				return attribute.KeyValue{}, false
+			}
+			out[i] = kv.Value.AsInt64()
+		}
+		return k.Int64Slice(out), true
+	case attribute.FLOAT64:
+		out := make([]float64, n)
+		for i := range out {
+			kv, ok := scalarAttribute(k, indirect(rv.Index(i)))
+			if !ok || kv.Value.Type() != attribute.FLOAT64 {
+				return attribute.KeyValue{}, false
+			}
+			out[i] = kv.Value.AsFloat64()
+		}
+		return k.Float64Slice(out), true
+	case attribute.STRING:
+		out := make([]string, n)
+		for i := range out {
+			kv, ok := scalarAttribute(k, indirect(rv.Index(i)))
+			if !ok || kv.Value.Type() != attribute.STRING {
+				return attribute.KeyValue{}, false
+			}
+			out[i] = kv.Value.AsString()
+		}
+		return k.StringSlice(out), true
+	}
+	return attribute.KeyValue{}, false
+}
+
+func indirect(rv reflect.Value) reflect.Value {
+	for rv.Kind() == reflect.Interface && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// structToMap converts a struct into a map using its JSON representation so
+// json tags are respected, numbers are kept as json.Number.
+func structToMap(v interface{}) (map[string]interface{}, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, false
+	}
+	return m, true
+}
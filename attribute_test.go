@@ -0,0 +1,138 @@
+package otools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// nested build a map n levels deep ending in "leaf"
+func nested(n int) interface{} {
+	if n == 0 {
+		return "leaf"
+	}
+	return map[string]interface{}{"n": nested(n - 1)}
+}
+
+func TestSetTagAttributes(t *testing.T) {
+	number := 7
+	var nilNumber *int
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []attribute.KeyValue
+	}{
+		{name: "string", value: "v", want: []attribute.KeyValue{attribute.String("k", "v")}},
+		{name: "bool", value: true, want: []attribute.KeyValue{attribute.Bool("k", true)}},
+		{name: "int", value: 42, want: []attribute.KeyValue{attribute.Int64("k", 42)}},
+		{name: "uint8", value: uint8(7), want: []attribute.KeyValue{attribute.Int64("k", 7)}},
+		{name: "max int64 as uint64", value: uint64(math.MaxInt64), want: []attribute.KeyValue{attribute.Int64("k", math.MaxInt64)}},
+		{name: "uint64 overflowing int64", value: uint64(math.MaxUint64), want: []attribute.KeyValue{attribute.String("k", "18446744073709551615")}},
+		{name: "float32", value: float32(1.5), want: []attribute.KeyValue{attribute.Float64("k", 1.5)}},
+		{name: "pointer", value: &number, want: []attribute.KeyValue{attribute.Int64("k", 7)}},
+		{name: "nil pointer", value: nilNumber, want: []attribute.KeyValue{attribute.String("k", "null")}},
+		{name: "nil", value: nil, want: []attribute.KeyValue{attribute.String("k", "null")}},
+		{name: "json integer", value: json.Number("12"), want: []attribute.KeyValue{attribute.Int64("k", 12)}},
+		{name: "json float", value: json.Number("1.5"), want: []attribute.KeyValue{attribute.Float64("k", 1.5)}},
+		{name: "error", value: errors.New("failed"), want: []attribute.KeyValue{attribute.String("k", "failed")}},
+		{name: "duration", value: 1500 * time.Millisecond, want: []attribute.KeyValue{attribute.String("k", "1.5s")}},
+		{name: "bytes", value: []byte("raw"), want: []attribute.KeyValue{attribute.String("k", "raw")}},
+		{name: "string slice", value: []string{"a", "b"}, want: []attribute.KeyValue{attribute.StringSlice("k", []string{"a", "b"})}},
+		{name: "int slice", value: []int{1, 2}, want: []attribute.KeyValue{attribute.IntSlice("k", []int{1, 2})}},
+		{name: "uint16 array", value: [2]uint16{1, 2}, want: []attribute.KeyValue{attribute.Int64Slice("k", []int64{1, 2})}},
+		{name: "float32 slice", value: []float32{0.5, 1}, want: []attribute.KeyValue{attribute.Float64Slice("k", []float64{0.5, 1})}},
+		{name: "interface slice of ints", value: []interface{}{1, int8(2)}, want: []attribute.KeyValue{attribute.Int64Slice("k", []int64{1, 2})}},
+		{name: "interface slice of bools", value: []interface{}{true, false}, want: []attribute.KeyValue{attribute.BoolSlice("k", []bool{true, false})}},
+		{name: "mixed int and string", value: []interface{}{1, "a"}, want: []attribute.KeyValue{attribute.String("k", `[1,"a"]`)}},
+		{name: "mixed int and float", value: []interface{}{1, 2.5}, want: []attribute.KeyValue{attribute.String("k", "[1,2.5]")}},
+		{name: "slice with uint64 overflow", value: []uint64{1, math.MaxUint64}, want: []attribute.KeyValue{attribute.String("k", "[1,18446744073709551615]")}},
+		{name: "empty slice", value: []interface{}{}, want: []attribute.KeyValue{attribute.String("k", "[]")}},
+		{name: "slice of maps", value: []map[string]int{{"a": 1}}, want: []attribute.KeyValue{attribute.String("k", `[{"a":1}]`)}},
+		{name: "map flattened", value: map[string]interface{}{"a": 1, "b": map[string]bool{"c": true}}, want: []attribute.KeyValue{
+			attribute.Int64("k.a", 1),
+			attribute.Bool("k.b.c", true),
+		}},
+		{name: "map with int keys", value: map[int]string{1: "a"}, want: []attribute.KeyValue{attribute.String("k", `{"1":"a"}`)}},
+		{name: "struct with json tags", value: struct {
+			Name  string  `json:"name"`
+			Age   int     `json:"age"`
+			Score float64 `json:"score"`
+			Skip  string  `json:"-"`
+		}{Name: "x", Age: 30, Score: 1.5, Skip: "hidden"}, want: []attribute.KeyValue{
+			attribute.Int64("k.age", 30),
+			attribute.String("k.name", "x"),
+			attribute.Float64("k.score", 1.5),
+		}},
+		{name: "depth limit", value: nested(7), want: []attribute.KeyValue{
+			attribute.String("k.n.n.n.n.n", `{"n":{"n":"leaf"}}`),
+		}},
+		{name: "within depth limit", value: nested(5), want: []attribute.KeyValue{
+			attribute.String("k.n.n.n.n.n", "leaf"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(recorder))
+			defer provider.Shutdown(context.Background())
+
+			tr := NewTelemetry().startTrace(context.Background(), provider.Tracer(toolName), "op")
+			tr.SetTag("k", tt.value)
+			tr.Finish()
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			got := attribute.NewSet(spans[0].Attributes()...)
+			if want := attribute.NewSet(tt.want...); !got.Equals(&want) {
+				t.Fatalf("got %v, want %v", got.ToSlice(), want.ToSlice())
+			}
+		})
+	}
+}
+
+func TestStructToMap(t *testing.T) {
+	type inner struct {
+		ID int64 `json:"id"`
+	}
+	tests := []struct {
+		name   string
+		in     interface{}
+		want   map[string]interface{}
+		wantOK bool
+	}{
+		{name: "json tags and numbers", in: struct {
+			Name  string `json:"name"`
+			Inner inner  `json:"inner"`
+		}{Name: "x", Inner: inner{ID: 9007199254740993}}, want: map[string]interface{}{
+			"name":  "x",
+			"inner": map[string]interface{}{"id": json.Number("9007199254740993")},
+		}, wantOK: true},
+		{name: "omitempty", in: struct {
+			Name string `json:"name,omitempty"`
+		}{}, want: map[string]interface{}{}, wantOK: true},
+		{name: "unsupported field", in: struct{ C chan int }{C: make(chan int)}, wantOK: false},
+		{name: "not an object", in: struct{}{}, want: map[string]interface{}{}, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := structToMap(tt.in)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package otools
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/log"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BatchConfig tune the batch processor of InitTracer and InitLog, zero fields
+// keep the SDK defaults
+type BatchConfig struct {
+	// QueueSize is the max number of spans or log records waiting for export,
+	// default 2048, items over it are dropped and counted, see Health
+	QueueSize int
+	// BatchSize is the max number of items in one export, default 512
+	BatchSize int
+	// ExportTimeout bound one export, default 30s
+	ExportTimeout time.Duration
+	// ScheduleDelay is the max delay between two exports, default 5s for
+	// spans and 1s for logs
+	ScheduleDelay time.Duration
+}
+
+func (c BatchConfig) queueSize() int {
+	if c.QueueSize > 0 {
+		return c.QueueSize
+	}
+	return defaultQueueSize
+}
+
+func (c BatchConfig) spanOptions() []tracesdk.BatchSpanProcessorOption {
+	opts := []tracesdk.BatchSpanProcessorOption{tracesdk.WithMaxQueueSize(c.queueSize())}
+	if c.BatchSize > 0 {
+		opts = append(opts, tracesdk.WithMaxExportBatchSize(c.BatchSize))
+	}
+	if c.ExportTimeout > 0 {
+		opts = append(opts, tracesdk.WithExportTimeout(c.ExportTimeout))
+	}
+	if c.ScheduleDelay > 0 {
+		opts = append(opts, tracesdk.WithBatchTimeout(c.ScheduleDelay))
+	}
+	return opts
+}
+
+func (c BatchConfig) logOptions() []log.BatchProcessorOption {
+	opts := []log.BatchProcessorOption{log.WithMaxQueueSize(c.queueSize())}
+	if c.BatchSize > 0 {
+		opts = append(opts, log.WithExportMaxBatchSize(c.BatchSize))
+	}
+	if c.ExportTimeout > 0 {
+		opts = append(opts, log.WithExportTimeout(c.ExportTimeout))
+	}
+	if c.ScheduleDelay > 0 {
+		opts = append(opts, log.WithExportInterval(c.ScheduleDelay))
+	}
+	return opts
+}
@@ -0,0 +1,187 @@
+package otools
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otermetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// DefaultCardinalityLimit is the number of distinct attribute sets recorded
+// per instrument unless WithCardinalityLimit is given to InitMetrics
+const DefaultCardinalityLimit = 2000
+
+// OverflowAttributeKey is set on measurements recorded after an instrument
+// reached its cardinality limit, they replace the original attributes
+const OverflowAttributeKey = attribute.Key("otel.metric.overflow")
+
+var overflowAttributes = otermetric.WithAttributeSet(attribute.NewSet(OverflowAttributeKey.Bool(true)))
+
+// limiterConfig is what InitMetrics configured for the cardinality limit, the
+// zero value disables it
+type limiterConfig struct {
+	limit       int
+	scope       instrumentation.Scope
+	view        metric.View
+	temporality metric.TemporalitySelector
+	interval    time.Duration
+}
+
+// cardinalityLimiter count the distinct attribute sets of one instrument, it
+// is embedded in every instrument returned by the helpers
+type cardinalityLimiter struct {
+	desc  InstrumentDescriptor
+	warn  func(ctx context.Context, format string, i ...interface{})
+	state atomic.Pointer[limiterState]
+}
+
+// limiterState is replaced on every InitMetrics, a set seen before is allowed
+// without taking mu
+type limiterState struct {
+	limit int
+	// filter is the attribute filter of the view, attributes it drops do
+	// not count
+	filter attribute.Filter
+	// window is the export interval with delta temporality, seen sets are
+	// forgotten once it passed. With cumulative temporality the SDK keeps
+	// every stream for the life of the provider so they are counted as long.
+	window time.Duration
+	seen   sync.Map
+
+	mu     sync.Mutex
+	count  int
+	reset  time.Time
+	warned bool
+}
+
+func (l *cardinalityLimiter) limiter() *cardinalityLimiter {
+	return l
+}
+
+// setup set the instrument and the logger used for the warning, before the
+// instrument is used
+func (l *cardinalityLimiter) setup(desc InstrumentDescriptor, warn func(ctx context.Context, format string, i ...interface{})) {
+	l.desc, l.warn = desc, warn
+}
+
+// setLimit forget the seen attribute sets and apply cfg
+func (l *cardinalityLimiter) setLimit(cfg limiterConfig) {
+	if cfg.limit <= 0 {
+		l.state.Store(nil)
+		return
+	}
+
+	s := &limiterState{limit: cfg.limit}
+	kind, ok := l.desc.Kind.sdkKind()
+	if ok && cfg.view != nil {
+		if stream, ok := cfg.view(metric.Instrument{
+			Name:        l.desc.Name,
+			Description: l.desc.Description,
+			Kind:        kind,
+			Unit:        l.desc.Unit,
+			Scope:       cfg.scope,
+		}); ok {
+			s.filter = stream.AttributeFilter
+		}
+	}
+	if ok && cfg.temporality != nil && cfg.interval > 0 && cfg.temporality(kind) == metricdata.DeltaTemporality {
+		s.window = cfg.interval
+		s.reset = time.Now().Add(cfg.interval)
+	}
+	l.state.Store(s)
+}
+
+// allow report whether set is below the limit, the first rejected set is logged
+func (l *cardinalityLimiter) allow(ctx context.Context, set attribute.Set) bool {
+	s := l.state.Load()
+	if s == nil {
+		return true
+	}
+
+	if s.filter != nil {
+		set, _ = set.Filter(s.filter)
+	}
+	key := set.Equivalent()
+	if _, ok := s.seen.Load(key); ok {
+		return true
+	}
+
+	s.mu.Lock()
+	if s.window > 0 {
+		if now := time.Now(); now.After(s.reset) {
+			s.seen.Range(func(k, _ any) bool {
+				s.seen.Delete(k)
+				return true
+			})
+			s.count = 0
+			s.reset = now.Add(s.window)
+		}
+	}
+	if _, ok := s.seen.Load(key); ok {
+		s.mu.Unlock()
+		return true
+	}
+	if s.count < s.limit {
+		s.seen.Store(key, struct{}{})
+		s.count++
+		s.mu.Unlock()
+		return true
+	}
+
+	warn := !s.warned && l.warn != nil
+	s.warned = true
+	s.mu.Unlock()
+
+	if warn {
+		l.warn(ctx, "metric %q reached cardinality limit %d, new attribute sets are recorded as %s", l.desc.Name, s.limit, OverflowAttributeKey)
+	}
+	return false
+}
+
+func (l *cardinalityLimiter) addOptions(ctx context.Context, options []otermetric.AddOption) []otermetric.AddOption {
+	if l.state.Load() == nil || l.allow(ctx, otermetric.NewAddConfig(options).Attributes()) {
+		return options
+	}
+	return []otermetric.AddOption{overflowAttributes}
+}
+
+func (l *cardinalityLimiter) recordOptions(ctx context.Context, options []otermetric.RecordOption) []otermetric.RecordOption {
+	if l.state.Load() == nil || l.allow(ctx, otermetric.NewRecordConfig(options).Attributes()) {
+		return options
+	}
+	return []otermetric.RecordOption{overflowAttributes}
+}
+
+func (l *cardinalityLimiter) observeOptions(ctx context.Context, options []otermetric.ObserveOption) []otermetric.ObserveOption {
+	if l.state.Load() == nil || l.allow(ctx, otermetric.NewObserveConfig(options).Attributes()) {
+		return options
+	}
+	return []otermetric.ObserveOption{overflowAttributes}
+}
+
+// sdkKind map k to the kind views are matched against
+func (k InstrumentKind) sdkKind() (metric.InstrumentKind, bool) {
+	switch k {
+	case InstrumentKindFloat64Histogram, InstrumentKindInt64Histogram:
+		return metric.InstrumentKindHistogram, true
+	case InstrumentKindInt64Counter, InstrumentKindFloat64Counter:
+		return metric.InstrumentKindCounter, true
+	case InstrumentKindInt64UpDownCounter:
+		return metric.InstrumentKindUpDownCounter, true
+	case InstrumentKindFloat64Gauge, InstrumentKindInt64Gauge:
+		return metric.InstrumentKindGauge, true
+	case InstrumentKindInt64ObservableCounter:
+		return metric.InstrumentKindObservableCounter, true
+	case InstrumentKindInt64ObservableUpDownCounter:
+		return metric.InstrumentKindObservableUpDownCounter, true
+	case InstrumentKindFloat64ObservableGauge:
+		return metric.InstrumentKindObservableGauge, true
+	}
+	return 0, false
+}
@@ -0,0 +1,82 @@
+package otools
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func newTestLimiter(opts ...MetricOption) *cardinalityLimiter {
+	l := &cardinalityLimiter{}
+	l.setup(InstrumentDescriptor{Name: "http.requests", Kind: InstrumentKindInt64Counter, Unit: "1"}, nil)
+	l.setLimit(newMetricConfig(opts...).limiterConfig("otools-metric"))
+	return l
+}
+
+func TestCardinalityLimiter(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []MetricOption
+		attrs     func(i int) []attribute.KeyValue
+		wantAllow int
+	}{
+		{
+			name:      "distinct sets over the limit",
+			opts:      []MetricOption{WithCardinalityLimit(2)},
+			attrs:     func(i int) []attribute.KeyValue { return []attribute.KeyValue{attribute.Int("user", i)} },
+			wantAllow: 2,
+		},
+		{
+			name: "attributes dropped by the allowlist do not count",
+			opts: []MetricOption{WithCardinalityLimit(2), WithAttributeAllowlist("http.requests", "route")},
+			attrs: func(i int) []attribute.KeyValue {
+				return []attribute.KeyValue{attribute.String("route", "/orders"), attribute.Int("request_id", i)}
+			},
+			wantAllow: 100,
+		},
+		{
+			name: "sensitive attributes do not count",
+			opts: []MetricOption{WithCardinalityLimit(2)},
+			attrs: func(i int) []attribute.KeyValue {
+				return []attribute.KeyValue{attribute.String("route", "/login"), attribute.String("password", fmt.Sprint(i))}
+			},
+			wantAllow: 100,
+		},
+		{
+			name:      "disabled",
+			opts:      []MetricOption{WithCardinalityLimit(0)},
+			attrs:     func(i int) []attribute.KeyValue { return []attribute.KeyValue{attribute.Int("user", i)} },
+			wantAllow: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(tt.opts...)
+			allowed := 0
+			for i := 0; i < 100; i++ {
+				if l.allow(context.Background(), attribute.NewSet(tt.attrs(i)...)) {
+					allowed++
+				}
+			}
+			if allowed != tt.wantAllow {
+				t.Fatalf("allowed %d sets, want %d", allowed, tt.wantAllow)
+			}
+		})
+	}
+}
+
+func TestCardinalityLimiterDeltaWindow(t *testing.T) {
+	l := newTestLimiter(WithCardinalityLimit(1), WithDeltaTemporality(), WithExportInterval(10*time.Millisecond))
+
+	first, second := attribute.NewSet(attribute.Int("user", 1)), attribute.NewSet(attribute.Int("user", 2))
+	if !l.allow(context.Background(), first) || l.allow(context.Background(), second) {
+		t.Fatal("want the second set over the limit")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !l.allow(context.Background(), second) {
+		t.Fatal("want the limit reset after the export interval")
+	}
+}
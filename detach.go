@@ -0,0 +1,70 @@
+package otools
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DetachContext return a context without the deadline and cancellation of
+// parent, it keeps the parent span context (sampled flag & tracestate),
+// baggage and the values of keys.
+func DetachContext(parent context.Context, keys ...interface{}) context.Context {
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(parent))
+	ctx = baggage.ContextWithBaggage(ctx, baggage.FromContext(parent))
+	for _, key := range keys {
+		if v := parent.Value(key); v != nil {
+			ctx = context.WithValue(ctx, key, v)
+		}
+	}
+	return ctx
+}
+
+// StartDetachedTrace start a span in a context detached from parentCtx, so
+// it is not canceled with the parent. By default the span is a child of the
+// parent span, WithFollowsFrom start a new trace linked to the parent span.
+func StartDetachedTrace(parentCtx context.Context, operationName string, opts ...StartOption) Tracer {
+	return defaultTelemetry.StartDetachedTrace(parentCtx, operationName, opts...)
+}
+
+// StartDetachedTrace see otools.StartDetachedTrace
+func (t *Telemetry) StartDetachedTrace(parentCtx context.Context, operationName string, opts ...StartOption) Tracer {
+	cfg := newStartConfig(opts...)
+	ctx := DetachContext(parentCtx, cfg.contextKeys...)
+
+	if parent := trace.SpanContextFromContext(parentCtx); cfg.followsFrom && parent.IsValid() {
+		opts = append(opts, WithNewRoot(), WithLinks(trace.Link{SpanContext: parent}))
+	}
+
+	return t.StartTrace(ctx, operationName, opts...)
+}
+
+// Go run fn in a goroutine with a detached traced context, the error returned
+// by fn and any panic are recorded on the span and logged, a panic does not
+// crash the process.
+func Go(ctx context.Context, operationName string, fn func(ctx context.Context) error, opts ...StartOption) {
+	defaultTelemetry.Go(ctx, operationName, fn, opts...)
+}
+
+// Go see otools.Go
+func (t *Telemetry) Go(ctx context.Context, operationName string, fn func(ctx context.Context) error, opts ...StartOption) {
+	tr := t.StartDetachedTrace(ctx, operationName, opts...)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("panic in %s: %v", operationName, r)
+				t.recordPanic(tr.Span(), r)
+				t.E(tr.Context(), err)
+			}
+			tr.Finish()
+		}()
+
+		if err := fn(tr.Context()); err != nil {
+			tr.SetError(err)
+			t.E(tr.Context(), err)
+		}
+	}()
+}
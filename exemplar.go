@@ -0,0 +1,90 @@
+package otools
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+)
+
+// ExemplarMode decides which measurements may become exemplars. Exemplars
+// carry the trace and span ID of the ctx given to Record or Add, so a
+// histogram bucket links to the trace that produced it.
+type ExemplarMode int
+
+const (
+	// ExemplarTraceBased keeps exemplars of measurements made in a sampled
+	// span, e.g. with the ctx of StartTrace. This is the SDK default.
+	ExemplarTraceBased ExemplarMode = iota
+	// ExemplarAlways keeps exemplars of every measurement
+	ExemplarAlways
+	// ExemplarOff disables exemplars
+	ExemplarOff
+)
+
+func (m ExemplarMode) filter() exemplar.Filter {
+	switch m {
+	case ExemplarAlways:
+		return exemplar.AlwaysOnFilter
+	case ExemplarOff:
+		return exemplar.AlwaysOffFilter
+	default:
+		return exemplar.TraceBasedFilter
+	}
+}
+
+// WithExemplars set the ExemplarMode, without it the SDK default is used which
+// can be changed with OTEL_METRICS_EXEMPLAR_FILTER
+func WithExemplars(mode ExemplarMode) MetricOption {
+	return func(c *metricConfig) {
+		c.exemplarFilter = mode.filter()
+	}
+}
+
+// metricFileEncoder write indented JSON to metric.json with exemplar trace and
+// span IDs in hex like GetTraceID, the default encoder writes them as base64
+type metricFileEncoder struct {
+	enc *json.Encoder
+}
+
+func newMetricFileEncoder(w io.Writer) *metricFileEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return &metricFileEncoder{enc: enc}
+}
+
+func (e *metricFileEncoder) Encode(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	return e.enc.Encode(hexIDs(doc))
+}
+
+// hexIDs replace the base64 TraceID and SpanID fields of exemplars with hex
+func hexIDs(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range val {
+			if s, ok := sub.(string); ok && (k == "TraceID" || k == "SpanID") {
+				if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+					val[k] = hex.EncodeToString(id)
+				}
+				continue
+			}
+			val[k] = hexIDs(sub)
+		}
+	case []interface{}:
+		for i, sub := range val {
+			val[i] = hexIDs(sub)
+		}
+	}
+	return v
+}
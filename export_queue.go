@@ -0,0 +1,60 @@
+package otools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rudiarta/otools/otrace"
+	"go.opentelemetry.io/otel/attribute"
+	otermetric "go.opentelemetry.io/otel/metric"
+)
+
+const (
+	// ExportQueueDepthMetric is the number of items in export requests waiting
+	// for the collector, see otrace.WithBuffer and otrace.WithPersistentQueue
+	ExportQueueDepthMetric = "otools.export.queue.depth"
+	// ExportQueueDroppedMetric is the number of items in export requests
+	// dropped by the queue
+	ExportQueueDroppedMetric = "otools.export.queue.dropped"
+)
+
+const exportQueueMeterName = "github.com/rudiarta/otools/export"
+
+// startExportQueueMetrics register the depth and dropped items of the
+// trace, metric and log collector connections on provider, by signal
+func (t *Telemetry) startExportQueueMetrics(provider otermetric.MeterProvider) error {
+	meter := provider.Meter(exportQueueMeterName)
+
+	depth, err1 := meter.Int64ObservableUpDownCounter(ExportQueueDepthMetric,
+		otermetric.WithDescription("Items of export requests waiting for the collector"),
+		otermetric.WithUnit("{item}"))
+	dropped, err2 := meter.Int64ObservableCounter(ExportQueueDroppedMetric,
+		otermetric.WithDescription("Items of export requests dropped by the export queue"),
+		otermetric.WithUnit("{item}"))
+	if err := errors.Join(err1, err2); err != nil {
+		return err
+	}
+
+	_, err := meter.RegisterCallback(func(_ context.Context, o otermetric.Observer) error {
+		for signal, conn := range t.exportConns() {
+			if conn == nil {
+				continue
+			}
+			attrs := otermetric.WithAttributes(attribute.String("signal", signal))
+			o.ObserveInt64(depth, int64(conn.Pending()), attrs)
+			o.ObserveInt64(dropped, int64(conn.Dropped()), attrs)
+		}
+		return nil
+	}, depth, dropped)
+	return err
+}
+
+// exportConns get the current collector connections by signal, it runs in
+// metric callbacks so it must not take the provider mutexes
+func (t *Telemetry) exportConns() map[string]*otrace.Conn {
+	return map[string]*otrace.Conn{
+		signalTrace:  t.traceConn.Load(),
+		signalMetric: t.metricConn.Load(),
+		signalLog:    t.logConn.Load(),
+	}
+}
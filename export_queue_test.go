@@ -0,0 +1,54 @@
+package otools
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	otermetric "go.opentelemetry.io/otel/metric"
+)
+
+func TestCollectWhileRegisteringObservables(t *testing.T) {
+	tel := NewTelemetry()
+	gauge := func(ctx context.Context, o otermetric.Float64Observer) error {
+		o.Observe(1)
+		return nil
+	}
+
+	// the first callback of a collection registers an observable while the
+	// SDK holds its pipeline lock, the export queue callback runs after it
+	var once sync.Once
+	registered := make(chan struct{})
+	first := tel.ObservableGaugeMetric("first", "first callback", "1", func(ctx context.Context, o otermetric.Float64Observer) error {
+		once.Do(func() {
+			go func() {
+				defer close(registered)
+				tel.ObservableGaugeMetric("cache.size", "cache size", "By", gauge).Unregister()
+			}()
+			// let the registration wait for the pipeline lock
+			time.Sleep(100 * time.Millisecond)
+		})
+		return nil
+	})
+	defer first.Unregister()
+
+	if err := tel.InitMetrics("", "svc", "local", WithPrometheus(), WithoutPushExporter(),
+		WithoutRuntimeMetrics(), WithoutProcessMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	defer tel.ShutDownMeterProvider()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tel.MetricsHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+		<-registered
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("collection and observable registration deadlocked")
+	}
+}
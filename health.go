@@ -0,0 +1,152 @@
+package otools
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rudiarta/otools/otrace"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	// errorLogBurst is how many SDK errors are logged per errorLogInterval
+	errorLogBurst    = 10
+	errorLogInterval = time.Minute
+)
+
+// HealthStatus is a snapshot of the telemetry pipelines, see Health
+type HealthStatus struct {
+	Trace  SignalHealth
+	Metric SignalHealth
+	Log    SignalHealth
+	// LastError is the last error reported by the OTel SDK
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// SignalHealth describe the pipeline of one signal, counters are totals since
+// the Telemetry was created
+type SignalHealth struct {
+	Initialized bool
+	// Exported, Failed, Buffered and Dropped count spans, metric data points
+	// or log records, Dropped is always 0 for metrics. Buffered items were
+	// kept by the collector connection for replay instead of being sent.
+	Exported uint64
+	Failed   uint64
+	Buffered uint64
+	Dropped  uint64
+	// QueueSize and QueueCapacity of the batch processor
+	QueueSize     int
+	QueueCapacity int
+	// RetryPending and RetryDropped are the items buffered by the current
+	// collector connection and not replayed yet or dropped, see otrace.Conn
+	RetryPending  int
+	RetryDropped  uint64
+	LastExport    time.Time
+	LastError     string
+	LastErrorTime time.Time
+}
+
+// Health get a snapshot of the trace, metric and log pipelines, e.g. for a
+// readiness or debug endpoint
+func Health() HealthStatus {
+	return defaultTelemetry.Health()
+}
+
+// Health see otools.Health
+func (t *Telemetry) Health() HealthStatus {
+	t.traceMu.RLock()
+	traceInit, traceConn := t.isInitTrace, t.traceConn.Load()
+	t.traceMu.RUnlock()
+
+	t.metricMu.RLock()
+	metricInit, metricConn := t.isInitMetric, t.metricConn.Load()
+	t.metricMu.RUnlock()
+
+	t.logMu.RLock()
+	logInit, logConn := t.loggerProvider != nil, t.logConn.Load()
+	t.logMu.RUnlock()
+
+	h := HealthStatus{
+		Trace:  signalHealth(traceInit, &t.traceStats, traceConn),
+		Metric: signalHealth(metricInit, &t.metricStats, metricConn),
+		Log:    signalHealth(logInit, &t.logStats, logConn),
+	}
+	if last := t.lastError.Load(); last != nil {
+		h.LastError, h.LastErrorTime = last.message, last.time
+	}
+	return h
+}
+
+func signalHealth(initialized bool, s *exportStats, conn *otrace.Conn) SignalHealth {
+	h := SignalHealth{
+		Initialized:  initialized,
+		Exported:     s.exported.Load(),
+		Failed:       s.failed.Load(),
+		Buffered:     s.buffered.Load(),
+		Dropped:      s.dropped.Load(),
+		RetryPending: conn.Pending(),
+		RetryDropped: conn.Dropped(),
+	}
+	if q := s.queue.Load(); q != nil {
+		h.QueueSize, h.QueueCapacity = int(q.size.Load()), int(q.max)
+	}
+	if last := s.lastExport.Load(); last > 0 {
+		h.LastExport = time.Unix(0, last)
+	}
+	if last := s.lastError.Load(); last != nil {
+		h.LastError, h.LastErrorTime = last.message, last.time
+	}
+	return h
+}
+
+// setErrorHandler route OTel SDK errors to the logger of the default
+// Telemetry, only the default Telemetry sets the global handler
+func (t *Telemetry) setErrorHandler() {
+	if !t.global {
+		return
+	}
+	t.errorHandlerOnce.Do(func() {
+		otel.SetErrorHandler(&errorHandler{t: t})
+	})
+}
+
+// errorHandler log at most errorLogBurst errors per errorLogInterval, the
+// number of suppressed errors is logged with the next logged error
+type errorHandler struct {
+	t *Telemetry
+
+	mu          sync.Mutex
+	windowStart time.Time
+	logged      int
+	suppressed  int
+}
+
+func (h *errorHandler) Handle(err error) {
+	if err == nil {
+		return
+	}
+	h.t.lastError.Store(&errorRecord{message: err.Error(), time: time.Now()})
+
+	h.mu.Lock()
+	now := time.Now()
+	if now.Sub(h.windowStart) >= errorLogInterval {
+		h.windowStart, h.logged = now, 0
+	}
+	if h.logged >= errorLogBurst {
+		h.suppressed++
+		h.mu.Unlock()
+		return
+	}
+	h.logged++
+	suppressed := h.suppressed
+	h.suppressed = 0
+	h.mu.Unlock()
+
+	if suppressed > 0 {
+		h.t.Ef(context.Background(), "otel: %v (%d errors suppressed)", err, suppressed)
+		return
+	}
+	h.t.Ef(context.Background(), "otel: %v", err)
+}
@@ -0,0 +1,288 @@
+package otools
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	otermetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/embedded"
+)
+
+// delegatingInstrument is implemented by every instrument returned by the
+// metric helpers. Instruments can be created before InitMetrics, e.g. as
+// package level vars, measurements are dropped until the meter is ready and
+// recorded afterwards. Re-initialising moves them to the new meter.
+type delegatingInstrument interface {
+	// setDelegate move the instrument to m of generation gen, a meter older
+	// than the current one is ignored
+	setDelegate(m otermetric.Meter, gen uint64) error
+	limiter() *cardinalityLimiter
+}
+
+// delegate hold the real instrument created from the current meter
+type delegate[T any] struct {
+	cardinalityLimiter
+	instrument atomic.Pointer[T]
+	create     func(m otermetric.Meter) (T, error)
+
+	mu  sync.Mutex
+	gen uint64
+}
+
+func newDelegate[T any](create func(m otermetric.Meter) (T, error)) delegate[T] {
+	return delegate[T]{create: create}
+}
+
+func (d *delegate[T]) setDelegate(m otermetric.Meter, gen uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if gen < d.gen {
+		return nil
+	}
+	instrument, err := d.create(m)
+	if err != nil {
+		return err
+	}
+	d.instrument.Store(&instrument)
+	d.gen = gen
+	return nil
+}
+
+func (d *delegate[T]) load() (T, bool) {
+	if instrument := d.instrument.Load(); instrument != nil {
+		return *instrument, true
+	}
+	var zero T
+	return zero, false
+}
+
+type float64Histogram struct {
+	embedded.Float64Histogram
+	delegate[otermetric.Float64Histogram]
+	unit string
+}
+
+func (h *float64Histogram) instrumentUnit() string {
+	return h.unit
+}
+
+func (h *float64Histogram) Record(ctx context.Context, incr float64, options ...otermetric.RecordOption) {
+	if instrument, ok := h.load(); ok {
+		instrument.Record(ctx, incr, h.recordOptions(ctx, options)...)
+	}
+}
+
+type int64Counter struct {
+	embedded.Int64Counter
+	delegate[otermetric.Int64Counter]
+}
+
+func (c *int64Counter) Add(ctx context.Context, incr int64, options ...otermetric.AddOption) {
+	if instrument, ok := c.load(); ok {
+		instrument.Add(ctx, incr, c.addOptions(ctx, options)...)
+	}
+}
+
+type int64UpDownCounter struct {
+	embedded.Int64UpDownCounter
+	delegate[otermetric.Int64UpDownCounter]
+}
+
+func (c *int64UpDownCounter) Add(ctx context.Context, incr int64, options ...otermetric.AddOption) {
+	if instrument, ok := c.load(); ok {
+		instrument.Add(ctx, incr, c.addOptions(ctx, options)...)
+	}
+}
+
+type float64Counter struct {
+	embedded.Float64Counter
+	delegate[otermetric.Float64Counter]
+}
+
+func (c *float64Counter) Add(ctx context.Context, incr float64, options ...otermetric.AddOption) {
+	if instrument, ok := c.load(); ok {
+		instrument.Add(ctx, incr, c.addOptions(ctx, options)...)
+	}
+}
+
+type int64Histogram struct {
+	embedded.Int64Histogram
+	delegate[otermetric.Int64Histogram]
+}
+
+func (h *int64Histogram) Record(ctx context.Context, incr int64, options ...otermetric.RecordOption) {
+	if instrument, ok := h.load(); ok {
+		instrument.Record(ctx, incr, h.recordOptions(ctx, options)...)
+	}
+}
+
+type float64Gauge struct {
+	embedded.Float64Gauge
+	delegate[otermetric.Float64Gauge]
+}
+
+func (g *float64Gauge) Record(ctx context.Context, value float64, options ...otermetric.RecordOption) {
+	if instrument, ok := g.load(); ok {
+		instrument.Record(ctx, value, g.recordOptions(ctx, options)...)
+	}
+}
+
+type int64Gauge struct {
+	embedded.Int64Gauge
+	delegate[otermetric.Int64Gauge]
+}
+
+func (g *int64Gauge) Record(ctx context.Context, value int64, options ...otermetric.RecordOption) {
+	if instrument, ok := g.load(); ok {
+		instrument.Record(ctx, value, g.recordOptions(ctx, options)...)
+	}
+}
+
+// Registration is returned for observable instruments, Unregister stops
+// the callback from being called
+type Registration interface {
+	Unregister() error
+}
+
+// observable register its callback on every meter it is delegated to, the
+// registration on the previous meter is removed
+type observable struct {
+	cardinalityLimiter
+	mu           sync.Mutex
+	register     func(m otermetric.Meter, l *cardinalityLimiter) (otermetric.Registration, error)
+	registration otermetric.Registration
+	unregistered bool
+	gen          uint64
+	// remove drop the observable from the instruments of its telemetry
+	remove func()
+}
+
+func (o *observable) setDelegate(m otermetric.Meter, gen uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.unregistered || gen < o.gen {
+		return nil
+	}
+	if o.registration != nil {
+		if err := o.registration.Unregister(); err != nil {
+			return err
+		}
+		o.registration = nil
+	}
+
+	registration, err := o.register(m, &o.cardinalityLimiter)
+	if err != nil {
+		return err
+	}
+	o.registration = registration
+	o.gen = gen
+	return nil
+}
+
+func (o *observable) Unregister() error {
+	err := o.unregister()
+	// after o.mu is released, remove takes metricMu
+	if o.remove != nil {
+		o.remove()
+	}
+	return err
+}
+
+func (o *observable) unregister() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.unregistered = true
+	if o.registration == nil {
+		return nil
+	}
+	err := o.registration.Unregister()
+	o.registration = nil
+	return err
+}
+
+// int64Observer adapt an Observer to the Int64Observer given to callbacks
+type int64Observer struct {
+	embedded.Int64Observer
+	ctx        context.Context
+	observer   otermetric.Observer
+	observable otermetric.Int64Observable
+	limiter    *cardinalityLimiter
+}
+
+func (o int64Observer) Observe(value int64, options ...otermetric.ObserveOption) {
+	o.observer.ObserveInt64(o.observable, value, o.limiter.observeOptions(o.ctx, options)...)
+}
+
+// float64Observer adapt an Observer to the Float64Observer given to callbacks
+type float64Observer struct {
+	embedded.Float64Observer
+	ctx        context.Context
+	observer   otermetric.Observer
+	observable otermetric.Float64Observable
+	limiter    *cardinalityLimiter
+}
+
+func (o float64Observer) Observe(value float64, options ...otermetric.ObserveOption) {
+	o.observer.ObserveFloat64(o.observable, value, o.limiter.observeOptions(o.ctx, options)...)
+}
+
+func newInt64Observable(create func(m otermetric.Meter) (otermetric.Int64Observable, error), callback otermetric.Int64Callback) *observable {
+	return &observable{register: func(m otermetric.Meter, l *cardinalityLimiter) (otermetric.Registration, error) {
+		instrument, err := create(m)
+		if err != nil {
+			return nil, err
+		}
+		return m.RegisterCallback(func(ctx context.Context, o otermetric.Observer) error {
+			return callback(ctx, int64Observer{ctx: ctx, observer: o, observable: instrument, limiter: l})
+		}, instrument)
+	}}
+}
+
+func newFloat64Observable(create func(m otermetric.Meter) (otermetric.Float64Observable, error), callback otermetric.Float64Callback) *observable {
+	return &observable{register: func(m otermetric.Meter, l *cardinalityLimiter) (otermetric.Registration, error) {
+		instrument, err := create(m)
+		if err != nil {
+			return nil, err
+		}
+		return m.RegisterCallback(func(ctx context.Context, o otermetric.Observer) error {
+			return callback(ctx, float64Observer{ctx: ctx, observer: o, observable: instrument, limiter: l})
+		}, instrument)
+	}}
+}
+
+// meterUpdate is a meter to delegate instruments to once metricMu is
+// released, registering on the meter takes the SDK pipeline lock and
+// collection holds that lock while it runs callbacks
+type meterUpdate struct {
+	meter       otermetric.Meter
+	gen         uint64
+	instruments []delegatingInstrument
+}
+
+// setInstrumentsMeter make m the meter of the next generation and apply the
+// cardinality limit, metricMu must be held. The returned update moves every
+// instrument to m once metricMu is released.
+func (t *Telemetry) setInstrumentsMeter(m otermetric.Meter) meterUpdate {
+	t.meter = m
+	t.meterGen++
+	for _, instrument := range t.instruments {
+		instrument.limiter().setLimit(t.cardinality)
+	}
+	return meterUpdate{meter: m, gen: t.meterGen, instruments: slices.Clone(t.instruments)}
+}
+
+// apply delegate the instruments of u, metricMu must not be held
+func (u meterUpdate) apply() []error {
+	var errs []error
+	for _, instrument := range u.instruments {
+		if err := instrument.setDelegate(u.meter, u.gen); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -4,88 +4,157 @@ import (
 	"context"
 	"errors"
 	llog "log"
-	"os"
 	"strings"
 	"syscall"
 
 	"github.com/rudiarta/otools/olog"
 	"github.com/rudiarta/otools/otrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
-	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
 	"go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
 )
 
-var loggerProvider *log.LoggerProvider
+// Calling InitLog again replaces the logger provider, the previous one is
+// flushed and shut down after the new one is in place. See LogOption for the
+// collector connection. An invalid host is returned as error and leaves the
+// current provider in place.
+func InitLog(host, serviceName, environment string, opts ...LogOption) error {
+	return defaultTelemetry.InitLog(host, serviceName, environment, opts...)
+}
 
-func InitLog(host, serviceName, environment string) {
+// InitLog see otools.InitLog, olog methods of t write to the new provider
+func (t *Telemetry) InitLog(host, serviceName, environment string, opts ...LogOption) error {
+	t.setErrorHandler()
 	ctx := context.Background()
-	var err error
 
 	// Create resource.
 	res := otrace.NewResource(serviceName, environment)
 
 	// Create a logger provider.
 	// You can pass this instance directly when creating bridges.
-	loggerProvider, err = newLoggerProvider(ctx, host, environment, res)
-	if err != nil {
-		panic(err)
+	provider, conn, err := t.newLoggerProvider(ctx, host, environment, res, newLogConfig(opts...))
+	if provider == nil {
+		return err
+	}
+
+	t.logMu.Lock()
+	oldProvider, oldConn, oldLogger := t.loggerProvider, t.logConn.Swap(conn), t.logger
+	t.loggerProvider = provider
+	if !t.global {
+		t.logger = olog.New(provider)
 	}
+	if t.global {
+		// Register as global logger provider so that it can be accessed global.LoggerProvider.
+		// Most log bridges use the global logger provider as default.
+		// If the global logger provider is not set then a no-op implementation
+		// is used, which fails to generate data.
+		// Set while holding logMu, in the same order as the swap.
+		global.SetLoggerProvider(provider)
+		// the global only forwards to the first provider, olog is moved
+		// to provider itself
+		olog.SetLoggerProvider(provider)
+	}
+	t.logMu.Unlock()
 
-	// Register as global logger provider so that it can be accessed global.LoggerProvider.
-	// Most log bridges use the global logger provider as default.
-	// If the global logger provider is not set then a no-op implementation
-	// is used, which fails to generate data.
-	global.SetLoggerProvider(loggerProvider)
+	t.shutdownLoggerProvider(oldProvider, oldConn, oldLogger)
+	return err
 }
 
-func newLoggerProvider(ctx context.Context, host, environment string, res *resource.Resource) (*log.LoggerProvider, error) {
-	var exporter log.Exporter
-	var err error
-	conn := otrace.NewGrpcConn(context.Background(), host)
+// With otrace.WithBlock the provider is returned together with
+// otrace.ErrNotReady when the collector is not reachable in time. In test
+// environment records are not exported, only the processors of
+// WithLogProcessors see them, e.g. to keep them in memory.
+func (t *Telemetry) newLoggerProvider(ctx context.Context, host, environment string, res *resource.Resource, cfg logConfig) (*log.LoggerProvider, *otrace.Conn, error) {
+	var (
+		exporter log.Exporter
+		conn     *otrace.Conn
+		connErr  error
+		err      error
+	)
 
 	switch {
 	case strings.Contains(environment, "local"):
-		f, err = os.OpenFile("log.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		// Write telemetry data to a file, closed when the provider shuts down.
+		exporter, err = otrace.NewFileLogExporter("log.json")
 		if err != nil {
-			olog.E(context.Background(), err)
+			return nil, nil, err
 		}
-
-		exporter, err = stdoutlog.New(
-			stdoutlog.WithWriter(f),
-		)
 	case strings.Contains(environment, "test"):
+		t.logStats.queue.Store(nil)
 	default:
-		exporter, err = otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn))
+		conn, connErr = otrace.Dial(ctx, host, cfg.dialOpts...)
+		if conn == nil {
+			return nil, nil, connErr
+		}
+		exporter, err = otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn.ClientConn))
 		if err != nil {
-			return nil, err
+			t.closeConn(conn)
+			return nil, nil, err
 		}
 	}
 
-	processor := log.NewBatchProcessor(exporter)
-	provider := log.NewLoggerProvider(
-		log.WithResource(res),
-		log.WithProcessor(processor),
-	)
-	return provider, nil
+	providerOpts := []log.LoggerProviderOption{log.WithResource(res)}
+	for _, p := range cfg.processors {
+		providerOpts = append(providerOpts, log.WithProcessor(p))
+	}
+	if exporter != nil {
+		providerOpts = append(providerOpts, log.WithProcessor(t.newLogProcessor(exporter, cfg)))
+	}
+	provider := log.NewLoggerProvider(providerOpts...)
+	return provider, conn, connErr
 }
 
 func ShutDownLogProvider() error {
-	if loggerProvider != nil {
-		if err := loggerProvider.ForceFlush(context.Background()); err != nil {
-			olog.DF(context.Background(), "Error flushing log provider: %v", err)
+	return defaultTelemetry.ShutDownLogProvider()
+}
+
+// ShutDownLogProvider see otools.ShutDownLogProvider
+func (t *Telemetry) ShutDownLogProvider() error {
+	t.logMu.Lock()
+	provider, conn, logger := t.loggerProvider, t.logConn.Swap(nil), t.logger
+	t.loggerProvider = nil
+	if t.global {
+		olog.SetLoggerProvider(nil)
+	} else {
+		t.logger = olog.New(nil)
+	}
+	t.logMu.Unlock()
+
+	t.shutdownLoggerProvider(provider, conn, logger)
+	return nil
+}
+
+func (t *Telemetry) shutdownLoggerProvider(provider *log.LoggerProvider, conn *otrace.Conn, logger *olog.Instance) {
+	if provider != nil {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.DF(context.Background(), "Error flushing log provider: %v", err)
 		}
-		if err := loggerProvider.Shutdown(context.Background()); err != nil {
-			olog.DF(context.Background(), "Error shutting down log provider: %v", err)
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.DF(context.Background(), "Error shutting down log provider: %v", err)
 		}
-		olog.D(context.Background(), "Shutting down & flushing log provider successfully")
+		t.D(context.Background(), "Shutting down & flushing log provider successfully")
 	}
+	t.closeConn(conn)
 
-	err := olog.Logger.Desugar().Sync()
+	if t.global {
+		logger = olog.Default()
+	}
+	if logger == nil {
+		return
+	}
+	err := logger.Sync()
 	if err != nil && !errors.Is(err, syscall.ENOTTY) {
 		llog.Println(err)
 	}
+}
 
-	return nil
+// closeConn close a collector connection once its provider is shut down
+func (t *Telemetry) closeConn(conn *otrace.Conn) {
+	if conn == nil {
+		return
+	}
+	if err := conn.Close(); err != nil {
+		t.E(context.Background(), err)
+	}
 }
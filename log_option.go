@@ -0,0 +1,55 @@
+package otools
+
+import (
+	"github.com/rudiarta/otools/otrace"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// LogOption configure InitLog
+type LogOption func(*logConfig)
+
+type logConfig struct {
+	dialOpts   []otrace.DialOption
+	batch      BatchConfig
+	simple     bool
+	processors []log.Processor
+}
+
+func newLogConfig(opts ...LogOption) logConfig {
+	var cfg logConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithLogDialOptions configure the collector connection, e.g.
+// otrace.WithBlock to wait for the collector or otrace.WithBuffer
+func WithLogDialOptions(opts ...otrace.DialOption) LogOption {
+	return func(c *logConfig) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// WithLogBatch tune the batch log processor, see BatchConfig
+func WithLogBatch(batch BatchConfig) LogOption {
+	return func(c *logConfig) {
+		c.batch = batch
+	}
+}
+
+// WithSimpleLogProcessor export every record synchronously when it is
+// emitted instead of in batches, for debugging only
+func WithSimpleLogProcessor() LogOption {
+	return func(c *logConfig) {
+		c.simple = true
+	}
+}
+
+// WithLogProcessors register additional log processors, they run before the
+// processor exporting records so changes they make to a record are exported
+func WithLogProcessors(processors ...log.Processor) LogOption {
+	return func(c *logConfig) {
+		c.processors = append(c.processors, processors...)
+	}
+}
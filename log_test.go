@@ -0,0 +1,89 @@
+package otools
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rudiarta/otools/olog"
+	"go.opentelemetry.io/otel/sdk/log"
+)
+
+// chdirTemp run the test in a temporary directory, the local environment
+// writes its files to the working directory
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestSimpleLogProcessorInstrumentConflict(t *testing.T) {
+	chdirTemp(t)
+	tel := NewTelemetry()
+	if err := tel.InitLog("", "svc", "local", WithSimpleLogProcessor()); err != nil {
+		t.Fatal(err)
+	}
+	defer tel.ShutDownLogProvider()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tel.CounterMetric("orders", "orders placed", "1")
+		// the conflict is logged and exported synchronously
+		tel.CounterMetric("orders", "orders created", "1")
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("CounterMetric deadlocked logging a conflict through the simple log processor")
+	}
+}
+
+// countingProcessor count the records emitted to it
+type countingProcessor struct {
+	log.Processor
+	n atomic.Int64
+}
+
+func (p *countingProcessor) OnEmit(ctx context.Context, r *log.Record) error {
+	p.n.Add(1)
+	return nil
+}
+
+func (p *countingProcessor) Enabled(context.Context, log.EnabledParameters) bool { return true }
+func (p *countingProcessor) Shutdown(context.Context) error                      { return nil }
+func (p *countingProcessor) ForceFlush(context.Context) error                    { return nil }
+
+func TestInitLogAgainReplacesGlobalLogger(t *testing.T) {
+	tel := &Telemetry{global: true}
+	defer tel.ShutDownLogProvider()
+	ctx := context.Background()
+
+	first, second := &countingProcessor{}, &countingProcessor{}
+	if err := tel.InitLog("", "svc", "test", WithLogProcessors(first)); err != nil {
+		t.Fatal(err)
+	}
+	tel.I(ctx, "to the first provider")
+
+	if err := tel.InitLog("", "svc", "test", WithLogProcessors(second)); err != nil {
+		t.Fatal(err)
+	}
+	tel.I(ctx, "to the second provider")
+	olog.I(ctx, "to the second provider")
+
+	if got := first.n.Load(); got != 1 {
+		t.Errorf("first provider got %d records, want 1", got)
+	}
+	// shutting down the first provider is logged to the second one too
+	if got := second.n.Load(); got < 2 {
+		t.Errorf("second provider got %d records, want at least 2", got)
+	}
+}
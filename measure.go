@@ -0,0 +1,156 @@
+package otools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otermetric "go.opentelemetry.io/otel/metric"
+)
+
+// OutcomeAttributeKey is set to "success" or "error" by TimeErr and Measure
+const OutcomeAttributeKey = attribute.Key("outcome")
+
+// unitInstrument is implemented by histograms from the metric helpers
+type unitInstrument interface {
+	instrumentUnit() string
+}
+
+// Time start a timer, the returned func records the elapsed time in the unit
+// of hg, e.g. defer otools.Time(ctx, hg, attrs...)(). Histograms not created
+// by otools are recorded in ms.
+func Time(ctx context.Context, hg otermetric.Float64Histogram, attrs ...attribute.KeyValue) func() {
+	start := time.Now()
+	return func() {
+		hg.Record(ctx, durationIn(histogramUnit(hg), time.Since(start)), otermetric.WithAttributes(attrs...))
+	}
+}
+
+// TimeErr is Time with an outcome attribute read from *err when the returned
+// func runs, e.g. with a named error return
+//
+//	defer otools.TimeErr(ctx, hg, &err)()
+//
+// When deferred like this a panic is recorded with outcome error and raised
+// again.
+func TimeErr(ctx context.Context, hg otermetric.Float64Histogram, err *error, attrs ...attribute.KeyValue) func() {
+	start := time.Now()
+	return func() {
+		var e error
+		if err != nil {
+			e = *err
+		}
+		r := recover()
+		if r != nil {
+			e = panicError(r)
+		}
+		recordAttrs := append(attrs[:len(attrs):len(attrs)], outcome(e))
+		hg.Record(ctx, durationIn(histogramUnit(hg), time.Since(start)), otermetric.WithAttributes(recordAttrs...))
+		if r != nil {
+			panic(r)
+		}
+	}
+}
+
+// MeasureOption configure Measure
+type MeasureOption func(*measureConfig)
+
+type measureConfig struct {
+	attributes []attribute.KeyValue
+	trace      bool
+	startOpts  []StartOption
+}
+
+// WithMeasureAttributes add attributes to the duration recorded by Measure
+func WithMeasureAttributes(attrs ...attribute.KeyValue) MeasureOption {
+	return func(c *measureConfig) {
+		c.attributes = append(c.attributes, attrs...)
+	}
+}
+
+// WithMeasureTrace also start a span named like the histogram around fn, the
+// returned error is set on the span
+func WithMeasureTrace(opts ...StartOption) MeasureOption {
+	return func(c *measureConfig) {
+		c.trace = true
+		c.startOpts = append(c.startOpts, opts...)
+	}
+}
+
+// Measure run fn and record its duration in ms to the histogram named name
+// with an outcome attribute, the error of fn is returned. A panic of fn is
+// recorded as error and raised again.
+func Measure(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...MeasureOption) error {
+	return defaultTelemetry.Measure(ctx, name, fn, opts...)
+}
+
+// Measure see otools.Measure
+func (t *Telemetry) Measure(ctx context.Context, name string, fn func(ctx context.Context) error, opts ...MeasureOption) (err error) {
+	var cfg measureConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.trace {
+		tt := t.StartTrace(ctx, name, cfg.startOpts...)
+		ctx = tt.Context()
+		defer tt.Finish()
+		defer func() { tt.SetError(err) }()
+	}
+
+	defer TimeErr(ctx, t.measureHistogram(name), &err, cfg.attributes...)()
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
+			panic(r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// panicError is the error recorded for a recovered panic
+func panicError(r interface{}) error {
+	return fmt.Errorf("panic: %v", r)
+}
+
+// measureHistogram reuse the histogram already created for name whatever its
+// description and unit, TimeErr records in its unit
+func (t *Telemetry) measureHistogram(name string) otermetric.Float64Histogram {
+	if hg, ok := t.lookupInstrument(name, InstrumentKindFloat64Histogram).(otermetric.Float64Histogram); ok {
+		return hg
+	}
+	return t.HistogramMetric(name, "", "ms")
+}
+
+func outcome(err error) attribute.KeyValue {
+	if err != nil {
+		return OutcomeAttributeKey.String("error")
+	}
+	return OutcomeAttributeKey.String("success")
+}
+
+func histogramUnit(hg otermetric.Float64Histogram) string {
+	if u, ok := hg.(unitInstrument); ok {
+		return u.instrumentUnit()
+	}
+	return "ms"
+}
+
+// durationIn convert d to unit, unknown units are treated as ms
+func durationIn(unit string, d time.Duration) float64 {
+	switch unit {
+	case "ns":
+		return float64(d.Nanoseconds())
+	case "us", "µs":
+		return float64(d) / float64(time.Microsecond)
+	case "s":
+		return d.Seconds()
+	case "min":
+		return d.Minutes()
+	case "h":
+		return d.Hours()
+	default:
+		return float64(d) / float64(time.Millisecond)
+	}
+}
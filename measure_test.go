@@ -0,0 +1,66 @@
+package otools
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMeasureReusesHistogram(t *testing.T) {
+	tel := NewTelemetry()
+	hg := tel.HistogramMetric("checkout", "checkout duration", "s")
+
+	for i := 0; i < 10; i++ {
+		if err := tel.Measure(context.Background(), "checkout", func(context.Context) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := tel.measureHistogram("checkout"); got != hg {
+		t.Fatal("Measure did not reuse the existing histogram")
+	}
+	if conflicts := tel.InstrumentConflicts(); len(conflicts) != 0 {
+		t.Fatalf("got conflicts %v, want none", conflicts)
+	}
+}
+
+func TestMeasurePanicRecordedAsError(t *testing.T) {
+	tel := NewTelemetry()
+	if err := tel.InitMetrics("", "svc", "local", WithPrometheus(), WithoutPushExporter(),
+		WithoutRuntimeMetrics(), WithoutProcessMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	defer tel.ShutDownMeterProvider()
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("got panic %v, want boom raised again", r)
+			}
+		}()
+		tel.Measure(context.Background(), "checkout", func(context.Context) error { panic("boom") })
+	}()
+	hg := tel.HistogramMetric("payment", "payment duration", "ms")
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("got panic %v, want boom raised again", r)
+			}
+		}()
+		var err error
+		defer TimeErr(context.Background(), hg, &err)()
+		panic("boom")
+	}()
+
+	rec := httptest.NewRecorder()
+	tel.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, name := range []string{"checkout", "payment"} {
+		if !strings.Contains(body, name+`_milliseconds_count{otel_scope_name`) {
+			t.Fatalf("%s not recorded:\n%s", name, body)
+		}
+	}
+	if strings.Contains(body, `outcome="success"`) || !strings.Contains(body, `outcome="error"`) {
+		t.Fatalf("want the panic recorded with outcome error:\n%s", body)
+	}
+}
@@ -2,14 +2,15 @@ package otools
 
 import (
 	"context"
-	"os"
+	"io"
+	"slices"
 	"strings"
-	"time"
 
-	"github.com/rudiarta/otools/olog"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rudiarta/otools/otrace"
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	otermetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/noop"
@@ -17,159 +18,395 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 )
 
-var isInitMetric bool = false
-var meter otermetric.Meter
-var meterProvider *metric.MeterProvider
-
 func ShutDownMeterProvider() error {
-	if meterProvider != nil {
-		if err := meterProvider.ForceFlush(context.Background()); err != nil {
-			olog.DF(context.Background(), "Error flushing metric provider: %v", err)
+	return defaultTelemetry.ShutDownMeterProvider()
+}
+
+// ShutDownMeterProvider see otools.ShutDownMeterProvider
+func (t *Telemetry) ShutDownMeterProvider() error {
+	t.metricMu.Lock()
+	provider, conn := t.meterProvider, t.metricConn.Swap(nil)
+	t.meterProvider = nil
+	t.promRegistry = nil
+	t.meter = nil
+	t.isInitMetric = false
+	t.metricMu.Unlock()
+
+	t.shutdownMeterProvider(provider, conn)
+	return nil
+}
+
+func (t *Telemetry) shutdownMeterProvider(provider *metric.MeterProvider, conn *otrace.Conn) {
+	if provider != nil {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.DF(context.Background(), "Error flushing metric provider: %v", err)
 		}
-		if err := meterProvider.Shutdown(context.Background()); err != nil {
-			olog.DF(context.Background(), "Error shutting down metric provider: %v", err)
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.DF(context.Background(), "Error shutting down metric provider: %v", err)
 		}
-		olog.D(context.Background(), "Shutting down & flushing metric provider successfully")
+		t.D(context.Background(), "Shutting down & flushing metric provider successfully")
 	}
-	return nil
+	t.closeConn(conn)
 }
 
-func InitMetrics(host, serviceName, environment string) error { // Not ready to use yet
+// Calling InitMetrics again replaces the meter provider, the previous one is
+// flushed and shut down after the new one is in place.
+// See MetricOption for views, histogram buckets, export interval, temporality,
+// cardinality limits, exemplars and the collector connection. An invalid host
+// is returned as error and leaves the current provider in place.
+func InitMetrics(host, serviceName, environment string, opts ...MetricOption) error { // Not ready to use yet
+	return defaultTelemetry.InitMetrics(host, serviceName, environment, opts...)
+}
+
+// meterVersion is the instrumentation version of the meter of the helpers
+const meterVersion = "v0.0.1"
+
+// InitMetrics see otools.InitMetrics
+func (t *Telemetry) InitMetrics(host, serviceName, environment string, opts ...MetricOption) error {
+	t.setErrorHandler()
+	cfg := newMetricConfig(opts...)
+	var (
+		readers   []metric.Reader
+		registry  *prometheus.Registry
+		fileExp   *otrace.FileMetricExporter
+		conn      *otrace.Conn
+		connErr   error
+		meterName = "otools-metric"
+	)
 
 	// Handle if env local or test metrics are not be exported
 	switch {
-	case strings.Contains(environment, "local"):
+	case strings.Contains(environment, "local") && !cfg.withoutPush:
 		var err error
-		f, err = os.OpenFile("metric.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			olog.E(context.Background(), err)
-		}
-
-		exp, _ := stdoutmetric.New(
-			stdoutmetric.WithWriter(f),
-			// Use human-readable output.
-			stdoutmetric.WithPrettyPrint(),
-			// Do not print timestamps for the demo.
-			stdoutmetric.WithoutTimestamps(),
-		)
-
-		reader := metric.NewPeriodicReader(exp,
-			metric.WithInterval(30*time.Second),
-			metric.WithTimeout(5*time.Second))
-		// reader := metric.NewManualReader()
-
-		meterProvider = metric.NewMeterProvider(
-			metric.WithResource(otrace.NewResource(serviceName, environment)),
-			metric.WithReader(reader),
+		fileExp, err = otrace.NewFileMetricExporter("metric.json",
+			// Use human-readable output with exemplar IDs in hex.
+			func(w io.Writer) stdoutmetric.Encoder { return newMetricFileEncoder(w) },
+			// Keep timestamps, WithoutTimestamps drops exemplars and they
+			// help to find the matching trace.
+			stdoutmetric.WithTemporalitySelector(cfg.temporality),
 		)
-
-		otel.SetMeterProvider(meterProvider)
-
-		meter = otel.Meter(
-			"otools-metric-test",
-			otermetric.WithInstrumentationVersion("v0.0.1"),
-			otermetric.WithSchemaURL(semconv.SchemaURL),
-		)
-
-		err = runtime.Start(runtime.WithMinimumReadMemStatsInterval(time.Second))
 		if err != nil {
-			olog.E(context.Background(), err)
+			return err
 		}
 
-		return nil
+		readers = append(readers, metric.NewPeriodicReader(t.newCountingMetricExporter(fileExp),
+			metric.WithInterval(cfg.interval),
+			metric.WithTimeout(cfg.timeout)))
+		meterName = "otools-metric-test"
 	case strings.Contains(environment, "test"):
-		meter = noop.NewMeterProvider().Meter("otools-metric-test")
+		t.metricMu.Lock()
+		oldProvider, oldConn := t.meterProvider, t.metricConn.Swap(nil)
+		t.meterProvider, t.promRegistry = nil, nil
+		t.isInitMetric = true
+		t.cardinality = cfg.limiterConfig("otools-metric-test")
+		update := t.setInstrumentsMeter(noop.NewMeterProvider().Meter("otools-metric-test"))
+		t.metricMu.Unlock()
+		t.logErrors(update.apply())
+
+		t.shutdownMeterProvider(oldProvider, oldConn)
 		return nil
-	}
+	case cfg.withoutPush:
+		// only pull readers, e.g. WithPrometheus
+	default:
+		conn, connErr = otrace.Dial(context.Background(), host, cfg.dialOpts...)
+		if conn == nil {
+			return connErr
+		}
 
-	conn := otrace.NewGrpcConn(context.Background(), host)
+		// This reader is used as a stand-in for a reader that will actually export
+		// data. See exporters in the go.opentelemetry.io/otel/exporters package
+		// for more information.
+		exp, err := otrace.NewExporterMetricGRPC(context.Background(), conn.ClientConn,
+			otlpmetricgrpc.WithTemporalitySelector(cfg.temporality))
+		if err != nil {
+			t.closeConn(conn)
+			return err
+		}
+		readers = append(readers, metric.NewPeriodicReader(t.newCountingMetricExporter(exp),
+			metric.WithInterval(cfg.interval),
+			metric.WithTimeout(cfg.timeout)))
+	}
 
-	// This reader is used as a stand-in for a reader that will actually export
-	// data. See exporters in the go.opentelemetry.io/otel/exporters package
-	// for more information.
-	exp, err := otrace.NewExporterMetricGRPC(context.Background(), conn)
-	if err != nil {
-		println(err)
+	if cfg.prometheus {
+		reader, reg, err := newPrometheusReader(cfg.prometheusOpts...)
+		if err != nil {
+			if fileExp != nil {
+				fileExp.Shutdown(context.Background())
+			}
+			t.closeConn(conn)
+			return err
+		}
+		readers = append(readers, reader)
+		registry = reg
 	}
-	reader := metric.NewPeriodicReader(exp,
-		metric.WithInterval(30*time.Second),
-		metric.WithTimeout(5*time.Second))
-	// reader := metric.NewManualReader()
 
-	meterProvider = metric.NewMeterProvider(
+	providerOpts := []metric.Option{
 		metric.WithResource(otrace.NewResource(serviceName, environment)),
-		metric.WithReader(reader),
-	)
-
-	otel.SetMeterProvider(meterProvider)
+		metric.WithView(cfg.view),
+	}
+	for _, reader := range readers {
+		providerOpts = append(providerOpts, metric.WithReader(reader))
+	}
+	if cfg.exemplarFilter != nil {
+		providerOpts = append(providerOpts, metric.WithExemplarFilter(cfg.exemplarFilter))
+	}
+	provider := metric.NewMeterProvider(providerOpts...)
 
-	meter = otel.Meter(
-		"otools-metric",
-		otermetric.WithInstrumentationVersion("v0.0.1"),
+	newMeter := provider.Meter(
+		meterName,
+		otermetric.WithInstrumentationVersion(meterVersion),
 		otermetric.WithSchemaURL(semconv.SchemaURL),
 	)
-
-	err = runtime.Start(runtime.WithMinimumReadMemStatsInterval(time.Second))
-	if err != nil {
-		olog.E(context.Background(), err)
+	t.metricMu.Lock()
+	oldProvider, oldConn := t.meterProvider, t.metricConn.Swap(conn)
+	t.meterProvider = provider
+	if t.global {
+		otel.SetMeterProvider(provider)
 	}
+	t.promRegistry = registry
+	t.isInitMetric = true
+	t.cardinality = cfg.limiterConfig(meterName)
+	update := t.setInstrumentsMeter(newMeter)
+	t.metricMu.Unlock()
+	t.logErrors(update.apply())
 
-	return nil
+	t.shutdownMeterProvider(oldProvider, oldConn)
+	t.startRuntimeMetrics(provider, cfg)
+
+	return connErr
 }
 
-func HistogramMetric(metricName, metriCDescription, unitType string) otermetric.Float64Histogram {
-	if !isInitMetric {
-		meter = noop.NewMeterProvider().Meter("otools-metric-test")
+// startRuntimeMetrics start runtime, process, exporter and export queue
+// instrumentation on provider, it stops with the provider on shutdown or re-initialisation
+func (t *Telemetry) startRuntimeMetrics(provider *metric.MeterProvider, cfg metricConfig) {
+	if cfg.runtimeMetrics {
+		if err := runtime.Start(
+			runtime.WithMeterProvider(provider),
+			runtime.WithMinimumReadMemStatsInterval(cfg.runtimeInterval),
+		); err != nil {
+			t.E(context.Background(), err)
+		}
+	}
+	if cfg.processMetrics {
+		if err := startProcessMetrics(provider, !cfg.runtimeMetrics); err != nil {
+			t.E(context.Background(), err)
+		}
 	}
+	if err := t.startExportQueueMetrics(provider); err != nil {
+		t.E(context.Background(), err)
+	}
+	if err := t.startExporterMetrics(provider); err != nil {
+		t.E(context.Background(), err)
+	}
+}
+
+// HistogramMetric create a histogram, it can be created before InitMetrics
+// and starts recording once metrics are initialised. buckets are the explicit
+// bucket boundaries, the SDK defaults are used when empty and views given to
+// InitMetrics take precedence. A later call for the same name gets the first
+// histogram, other non-empty buckets are reported by InstrumentConflicts.
+func HistogramMetric(metricName, metriCDescription, unitType string, buckets ...float64) otermetric.Float64Histogram {
+	return defaultTelemetry.HistogramMetric(metricName, metriCDescription, unitType, buckets...)
+}
 
+// HistogramMetric see otools.HistogramMetric
+func (t *Telemetry) HistogramMetric(metricName, metriCDescription, unitType string, buckets ...float64) otermetric.Float64Histogram {
 	if unitType == "" {
 		unitType = "ms"
 	}
-	histogram, err := meter.Float64Histogram(metricName,
-		otermetric.WithDescription(metriCDescription),
-		otermetric.WithUnit(unitType))
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindFloat64Histogram, Description: metriCDescription, Unit: unitType, Buckets: slices.Clone(buckets)}, true,
+		func() delegatingInstrument {
+			return &float64Histogram{unit: unitType, delegate: newDelegate(func(m otermetric.Meter) (otermetric.Float64Histogram, error) {
+				return m.Float64Histogram(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType),
+					otermetric.WithExplicitBucketBoundaries(buckets...))
+			})}
+		}).(otermetric.Float64Histogram)
+}
+
+// CounterMetric create a counter, it can be created before InitMetrics
+// and starts recording once metrics are initialised
+func CounterMetric(metricName, metriCDescription, unitType string) otermetric.Int64Counter {
+	return defaultTelemetry.CounterMetric(metricName, metriCDescription, unitType)
+}
 
-	if err != nil {
-		olog.E(context.Background(), err)
+// CounterMetric see otools.CounterMetric
+func (t *Telemetry) CounterMetric(metricName, metriCDescription, unitType string) otermetric.Int64Counter {
+	if unitType == "" {
+		unitType = "1"
 	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindInt64Counter, Description: metriCDescription, Unit: unitType}, true,
+		func() delegatingInstrument {
+			return &int64Counter{delegate: newDelegate(func(m otermetric.Meter) (otermetric.Int64Counter, error) {
+				return m.Int64Counter(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			})}
+		}).(otermetric.Int64Counter)
+}
 
-	return histogram
+// UpDownCounterMetric create an up-down counter, it can be created before
+// InitMetrics and starts recording once metrics are initialised
+func UpDownCounterMetric(metricName, metriCDescription, unitType string) otermetric.Int64UpDownCounter {
+	return defaultTelemetry.UpDownCounterMetric(metricName, metriCDescription, unitType)
 }
 
-func CounterMetric(metricName, metriCDescription, unitType string) otermetric.Int64Counter {
-	if !isInitMetric {
-		meter = noop.NewMeterProvider().Meter("otools-metric-test")
+// UpDownCounterMetric see otools.UpDownCounterMetric
+func (t *Telemetry) UpDownCounterMetric(metricName, metriCDescription, unitType string) otermetric.Int64UpDownCounter {
+	if unitType == "" {
+		unitType = "1"
 	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindInt64UpDownCounter, Description: metriCDescription, Unit: unitType}, true,
+		func() delegatingInstrument {
+			return &int64UpDownCounter{delegate: newDelegate(func(m otermetric.Meter) (otermetric.Int64UpDownCounter, error) {
+				return m.Int64UpDownCounter(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			})}
+		}).(otermetric.Int64UpDownCounter)
+}
+
+// Float64CounterMetric create a float counter
+func Float64CounterMetric(metricName, metriCDescription, unitType string) otermetric.Float64Counter {
+	return defaultTelemetry.Float64CounterMetric(metricName, metriCDescription, unitType)
+}
 
+// Float64CounterMetric see otools.Float64CounterMetric
+func (t *Telemetry) Float64CounterMetric(metricName, metriCDescription, unitType string) otermetric.Float64Counter {
 	if unitType == "" {
 		unitType = "1"
 	}
-	upDownCounter, err := meter.Int64Counter(metricName,
-		otermetric.WithDescription(metriCDescription),
-		otermetric.WithUnit(unitType))
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindFloat64Counter, Description: metriCDescription, Unit: unitType}, true,
+		func() delegatingInstrument {
+			return &float64Counter{delegate: newDelegate(func(m otermetric.Meter) (otermetric.Float64Counter, error) {
+				return m.Float64Counter(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			})}
+		}).(otermetric.Float64Counter)
+}
+
+// Int64HistogramMetric create an int histogram, see HistogramMetric for buckets
+func Int64HistogramMetric(metricName, metriCDescription, unitType string, buckets ...float64) otermetric.Int64Histogram {
+	return defaultTelemetry.Int64HistogramMetric(metricName, metriCDescription, unitType, buckets...)
+}
 
-	if err != nil {
-		olog.E(context.Background(), err)
+// Int64HistogramMetric see otools.Int64HistogramMetric
+func (t *Telemetry) Int64HistogramMetric(metricName, metriCDescription, unitType string, buckets ...float64) otermetric.Int64Histogram {
+	if unitType == "" {
+		unitType = "ms"
 	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindInt64Histogram, Description: metriCDescription, Unit: unitType, Buckets: slices.Clone(buckets)}, true,
+		func() delegatingInstrument {
+			return &int64Histogram{delegate: newDelegate(func(m otermetric.Meter) (otermetric.Int64Histogram, error) {
+				return m.Int64Histogram(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType),
+					otermetric.WithExplicitBucketBoundaries(buckets...))
+			})}
+		}).(otermetric.Int64Histogram)
+}
 
-	return upDownCounter
+// GaugeMetric create a synchronous gauge recording the current value
+func GaugeMetric(metricName, metriCDescription, unitType string) otermetric.Float64Gauge {
+	return defaultTelemetry.GaugeMetric(metricName, metriCDescription, unitType)
 }
 
-func UpDownCounterMetric(metricName, metriCDescription, unitType string) otermetric.Int64UpDownCounter {
-	if !isInitMetric {
-		meter = noop.NewMeterProvider().Meter("otools-metric-test")
+// GaugeMetric see otools.GaugeMetric
+func (t *Telemetry) GaugeMetric(metricName, metriCDescription, unitType string) otermetric.Float64Gauge {
+	if unitType == "" {
+		unitType = "1"
 	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindFloat64Gauge, Description: metriCDescription, Unit: unitType}, true,
+		func() delegatingInstrument {
+			return &float64Gauge{delegate: newDelegate(func(m otermetric.Meter) (otermetric.Float64Gauge, error) {
+				return m.Float64Gauge(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			})}
+		}).(otermetric.Float64Gauge)
+}
+
+// Int64GaugeMetric create a synchronous int gauge recording the current value
+func Int64GaugeMetric(metricName, metriCDescription, unitType string) otermetric.Int64Gauge {
+	return defaultTelemetry.Int64GaugeMetric(metricName, metriCDescription, unitType)
+}
+
+// Int64GaugeMetric see otools.Int64GaugeMetric
+func (t *Telemetry) Int64GaugeMetric(metricName, metriCDescription, unitType string) otermetric.Int64Gauge {
+	if unitType == "" {
+		unitType = "1"
+	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindInt64Gauge, Description: metriCDescription, Unit: unitType}, true,
+		func() delegatingInstrument {
+			return &int64Gauge{delegate: newDelegate(func(m otermetric.Meter) (otermetric.Int64Gauge, error) {
+				return m.Int64Gauge(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			})}
+		}).(otermetric.Int64Gauge)
+}
+
+// ObservableCounterMetric create a monotonic counter read by callback on
+// every collection, e.g. total bytes read from a client library
+func ObservableCounterMetric(metricName, metriCDescription, unitType string, callback otermetric.Int64Callback) Registration {
+	return defaultTelemetry.ObservableCounterMetric(metricName, metriCDescription, unitType, callback)
+}
 
+// ObservableCounterMetric see otools.ObservableCounterMetric
+func (t *Telemetry) ObservableCounterMetric(metricName, metriCDescription, unitType string, callback otermetric.Int64Callback) Registration {
 	if unitType == "" {
 		unitType = "1"
 	}
-	upDownCounter, err := meter.Int64UpDownCounter(metricName,
-		otermetric.WithDescription(metriCDescription),
-		otermetric.WithUnit(unitType))
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindInt64ObservableCounter, Description: metriCDescription, Unit: unitType}, false,
+		func() delegatingInstrument {
+			return newInt64Observable(func(m otermetric.Meter) (otermetric.Int64Observable, error) {
+				return m.Int64ObservableCounter(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			}, callback)
+		}).(Registration)
+}
+
+// ObservableUpDownCounterMetric create an up-down counter read by callback on
+// every collection, e.g. queue depth
+func ObservableUpDownCounterMetric(metricName, metriCDescription, unitType string, callback otermetric.Int64Callback) Registration {
+	return defaultTelemetry.ObservableUpDownCounterMetric(metricName, metriCDescription, unitType, callback)
+}
 
-	if err != nil {
-		olog.E(context.Background(), err)
+// ObservableUpDownCounterMetric see otools.ObservableUpDownCounterMetric
+func (t *Telemetry) ObservableUpDownCounterMetric(metricName, metriCDescription, unitType string, callback otermetric.Int64Callback) Registration {
+	if unitType == "" {
+		unitType = "1"
 	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindInt64ObservableUpDownCounter, Description: metriCDescription, Unit: unitType}, false,
+		func() delegatingInstrument {
+			return newInt64Observable(func(m otermetric.Meter) (otermetric.Int64Observable, error) {
+				return m.Int64ObservableUpDownCounter(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			}, callback)
+		}).(Registration)
+}
 
-	return upDownCounter
+// ObservableGaugeMetric create a gauge read by callback on every collection,
+// e.g. cache size
+func ObservableGaugeMetric(metricName, metriCDescription, unitType string, callback otermetric.Float64Callback) Registration {
+	return defaultTelemetry.ObservableGaugeMetric(metricName, metriCDescription, unitType, callback)
+}
+
+// ObservableGaugeMetric see otools.ObservableGaugeMetric
+func (t *Telemetry) ObservableGaugeMetric(metricName, metriCDescription, unitType string, callback otermetric.Float64Callback) Registration {
+	if unitType == "" {
+		unitType = "1"
+	}
+	return t.registerInstrument(InstrumentDescriptor{Name: metricName, Kind: InstrumentKindFloat64ObservableGauge, Description: metriCDescription, Unit: unitType}, false,
+		func() delegatingInstrument {
+			return newFloat64Observable(func(m otermetric.Meter) (otermetric.Float64Observable, error) {
+				return m.Float64ObservableGauge(metricName,
+					otermetric.WithDescription(metriCDescription),
+					otermetric.WithUnit(unitType))
+			}, callback)
+		}).(Registration)
 }
@@ -0,0 +1,258 @@
+package otools
+
+import (
+	"time"
+
+	"github.com/rudiarta/otools/otrace"
+	"github.com/rudiarta/otools/outils"
+	"go.opentelemetry.io/otel/attribute"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// MetricOption configure InitMetrics
+type MetricOption func(*metricConfig)
+
+type metricConfig struct {
+	views            []metric.View
+	allowlists       map[string]attribute.Filter
+	interval         time.Duration
+	timeout          time.Duration
+	temporality      metric.TemporalitySelector
+	cardinalityLimit int
+	prometheus       bool
+	prometheusOpts   []otelprometheus.Option
+	withoutPush      bool
+	runtimeMetrics   bool
+	runtimeInterval  time.Duration
+	processMetrics   bool
+	exemplarFilter   exemplar.Filter
+	dialOpts         []otrace.DialOption
+}
+
+func newMetricConfig(opts ...MetricOption) metricConfig {
+	cfg := metricConfig{
+		interval:         30 * time.Second,
+		timeout:          5 * time.Second,
+		temporality:      metric.DefaultTemporalitySelector,
+		cardinalityLimit: DefaultCardinalityLimit,
+		runtimeMetrics:   true,
+		runtimeInterval:  time.Second,
+		processMetrics:   true,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithViews add OTel views, e.g. to rename a metric, drop attributes or change
+// the aggregation. When several views match one instrument the first one wins,
+// sensitive attributes are dropped regardless of the view.
+func WithViews(views ...metric.View) MetricOption {
+	return func(c *metricConfig) {
+		c.views = append(c.views, views...)
+	}
+}
+
+// WithHistogramBuckets set explicit bucket boundaries for histograms named
+// instrumentName, "*" can be used as wildcard, e.g. "http.*" or "*" for
+// every histogram
+func WithHistogramBuckets(instrumentName string, boundaries ...float64) MetricOption {
+	return WithViews(metric.NewView(
+		metric.Instrument{Name: instrumentName, Kind: metric.InstrumentKindHistogram},
+		metric.Stream{Aggregation: metric.AggregationExplicitBucketHistogram{Boundaries: boundaries}},
+	))
+}
+
+// WithExponentialHistogram use a base-2 exponential histogram for histograms
+// named instrumentName, "*" can be used as wildcard. Zero maxSize and maxScale
+// use 160 buckets and scale 20.
+func WithExponentialHistogram(instrumentName string, maxSize, maxScale int32) MetricOption {
+	if maxSize == 0 {
+		maxSize = 160
+	}
+	if maxScale == 0 {
+		maxScale = 20
+	}
+	return WithViews(metric.NewView(
+		metric.Instrument{Name: instrumentName, Kind: metric.InstrumentKindHistogram},
+		metric.Stream{Aggregation: metric.AggregationBase2ExponentialHistogram{MaxSize: maxSize, MaxScale: maxScale}},
+	))
+}
+
+// WithExportInterval set how often metrics are exported, default 30s
+func WithExportInterval(interval time.Duration) MetricOption {
+	return func(c *metricConfig) {
+		if interval > 0 {
+			c.interval = interval
+		}
+	}
+}
+
+// WithExportTimeout set the timeout of one export, default 5s
+func WithExportTimeout(timeout time.Duration) MetricOption {
+	return func(c *metricConfig) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// WithTemporality set the temporality per instrument kind, default cumulative
+func WithTemporality(selector metric.TemporalitySelector) MetricOption {
+	return func(c *metricConfig) {
+		if selector != nil {
+			c.temporality = selector
+		}
+	}
+}
+
+// WithDeltaTemporality export counters and histograms as delta, up-down
+// counters and gauges stay cumulative
+func WithDeltaTemporality() MetricOption {
+	return WithTemporality(deltaTemporality)
+}
+
+func deltaTemporality(kind metric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case metric.InstrumentKindCounter,
+		metric.InstrumentKindHistogram,
+		metric.InstrumentKindObservableCounter:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
+
+// WithAttributeAllowlist keep only the attributes with keys for the instrument
+// named instrumentName, other attributes are dropped before aggregation
+func WithAttributeAllowlist(instrumentName string, keys ...string) MetricOption {
+	return func(c *metricConfig) {
+		if c.allowlists == nil {
+			c.allowlists = map[string]attribute.Filter{}
+		}
+		attrKeys := make([]attribute.Key, 0, len(keys))
+		for _, k := range keys {
+			attrKeys = append(attrKeys, attribute.Key(k))
+		}
+		c.allowlists[instrumentName] = attribute.NewAllowKeysFilter(attrKeys...)
+	}
+}
+
+// WithCardinalityLimit limit the distinct attribute sets recorded per
+// instrument, default DefaultCardinalityLimit. Sets are counted after views
+// and allowlists dropped attributes, per export interval with delta
+// temporality and since InitMetrics otherwise. Measurements over the limit are
+// recorded with only the OverflowAttributeKey attribute and a warning is
+// logged once. limit <= 0 disables the limit.
+func WithCardinalityLimit(limit int) MetricOption {
+	return func(c *metricConfig) {
+		c.cardinalityLimit = limit
+	}
+}
+
+// WithPrometheus add a Prometheus reader next to the OTLP exporter, serve it
+// with MetricsHandler
+func WithPrometheus(opts ...otelprometheus.Option) MetricOption {
+	return func(c *metricConfig) {
+		c.prometheus = true
+		c.prometheusOpts = append(c.prometheusOpts, opts...)
+	}
+}
+
+// WithoutPushExporter skip the OTLP exporter, or metric.json for local
+// environment, e.g. together with WithPrometheus when there is no collector
+func WithoutPushExporter() MetricOption {
+	return func(c *metricConfig) {
+		c.withoutPush = true
+	}
+}
+
+// WithoutRuntimeMetrics skip the runtime instrumentation of
+// go.opentelemetry.io/contrib/instrumentation/runtime
+func WithoutRuntimeMetrics() MetricOption {
+	return func(c *metricConfig) {
+		c.runtimeMetrics = false
+	}
+}
+
+// WithRuntimeMetricsInterval set the minimum interval between MemStats reads
+// of the runtime instrumentation, default 1s
+func WithRuntimeMetricsInterval(interval time.Duration) MetricOption {
+	return func(c *metricConfig) {
+		if interval > 0 {
+			c.runtimeInterval = interval
+		}
+	}
+}
+
+// WithoutProcessMetrics skip the process metrics: CPU time, RSS, open FDs,
+// GC pause histogram, the build.info gauge and the goroutine count, which is
+// only added by the process metrics with WithoutRuntimeMetrics
+func WithoutProcessMetrics() MetricOption {
+	return func(c *metricConfig) {
+		c.processMetrics = false
+	}
+}
+
+// WithMetricDialOptions configure the collector connection, e.g.
+// otrace.WithBlock to wait for the collector or otrace.WithBuffer
+func WithMetricDialOptions(opts ...otrace.DialOption) MetricOption {
+	return func(c *metricConfig) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// limiterConfig is the cardinality limit of instruments created by the meter
+// named meterName
+func (c metricConfig) limiterConfig(meterName string) limiterConfig {
+	return limiterConfig{
+		limit:       c.cardinalityLimit,
+		scope:       instrumentation.Scope{Name: meterName, Version: meterVersion, SchemaURL: semconv.SchemaURL},
+		view:        c.view,
+		temporality: c.temporality,
+		interval:    c.interval,
+	}
+}
+
+// view combine the configured views into the single view given to the meter
+// provider, the SDK creates one stream per matching view so they can not be
+// registered separately. Allowlists apply on top of the view, attributes with
+// a sensitive key according to outils.GetRedactor are always dropped and the
+// overflow attribute is always kept.
+func (c metricConfig) view(inst metric.Instrument) (metric.Stream, bool) {
+	stream := metric.Stream{
+		Name:        inst.Name,
+		Description: inst.Description,
+		Unit:        inst.Unit,
+	}
+	for _, v := range c.views {
+		if s, ok := v(inst); ok {
+			stream = s
+			break
+		}
+	}
+
+	if stream.Name == "" {
+		stream.Name = inst.Name
+	}
+	filter, allow := stream.AttributeFilter, c.allowlists[inst.Name]
+	stream.AttributeFilter = func(kv attribute.KeyValue) bool {
+		if kv.Key == OverflowAttributeKey {
+			return true
+		}
+		if outils.IsSensitiveKey(string(kv.Key)) {
+			return false
+		}
+		if allow != nil && !allow(kv) {
+			return false
+		}
+		return filter == nil || filter(kv)
+	}
+	return stream, true
+}
@@ -2,9 +2,12 @@ package olog
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/rudiarta/otools/outils"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func getTraceIDFromContext(ctx context.Context) string {
@@ -17,18 +20,54 @@ func getTraceIDFromContext(ctx context.Context) string {
 	return span.SpanContext().TraceID().String()
 }
 
+// redact removes sensitive data from every log argument, see outils.Redactor
+func redact(values []interface{}) []interface{} {
+	r := outils.GetRedactor()
+	if r == nil {
+		return values
+	}
+
+	redacted := make([]interface{}, len(values))
+	for i, v := range values {
+		redacted[i] = r.Redact(v)
+	}
+	return redacted
+}
+
+// with add the trace ID and ctx to the entry. ctx is only given to the OTel
+// core to correlate the record with the span, it is not encoded since its
+// values can hold anything
+func (in *Instance) with(ctx context.Context) *zap.SugaredLogger {
+	return in.get().With(
+		zap.String("trace-id", getTraceIDFromContext(ctx)),
+		zap.Field{Key: "context", Type: zapcore.SkipType, Interface: ctx},
+	)
+}
+
+// sprint build the message like zap does and redact it
+func sprint(values ...interface{}) string {
+	return outils.RedactString(fmt.Sprint(values...))
+}
+
+// sprintf format the redacted arguments and redact the message, the format
+// itself can hold sensitive values
+func sprintf(format string, values []interface{}) string {
+	return outils.RedactString(fmt.Sprintf(format, redact(values)...))
+}
+
 // LogE error
 //
 //	func E(message interface{}) {
 //		logger.Error(message)
 //	}
 func E(ctx context.Context, message interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.E(ctx, message)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Error(message)
+// E see olog.E
+func (in *Instance) E(ctx context.Context, message interface{}) {
+	l := in.with(ctx)
+	l.Error(sprint(outils.Redact(message)))
 }
 
 // LogEf error with format
@@ -37,12 +76,13 @@ func E(ctx context.Context, message interface{}) {
 //		logger.Errorf(format, i...)
 //	}
 func Ef(ctx context.Context, format string, i ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.Ef(ctx, format, i...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Errorf(format, i...)
+// Ef see olog.Ef
+func (in *Instance) Ef(ctx context.Context, format string, i ...interface{}) {
+	l := in.with(ctx)
+	l.Error(sprintf(format, i))
 }
 
 // LogI info
@@ -51,12 +91,13 @@ func Ef(ctx context.Context, format string, i ...interface{}) {
 //		logger.Info(message...)
 //	}
 func I(ctx context.Context, message ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.I(ctx, message...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Info(message...)
+// I see olog.I
+func (in *Instance) I(ctx context.Context, message ...interface{}) {
+	l := in.with(ctx)
+	l.Info(sprint(redact(message)...))
 }
 
 // LogIf info with format
@@ -65,12 +106,13 @@ func I(ctx context.Context, message ...interface{}) {
 //		logger.Infof(format, i...)
 //	}
 func If(ctx context.Context, format string, i ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.If(ctx, format, i...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Infof(format, i...)
+// If see olog.If
+func (in *Instance) If(ctx context.Context, format string, i ...interface{}) {
+	l := in.with(ctx)
+	l.Info(sprintf(format, i))
 }
 
 // LogD info
@@ -79,12 +121,13 @@ func If(ctx context.Context, format string, i ...interface{}) {
 //		logger.Debug(message...)
 //	}
 func D(ctx context.Context, message ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.D(ctx, message...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Debug(message...)
+// D see olog.D
+func (in *Instance) D(ctx context.Context, message ...interface{}) {
+	l := in.with(ctx)
+	l.Debug(sprint(redact(message)...))
 }
 
 // DF info with format
@@ -93,12 +136,13 @@ func D(ctx context.Context, message ...interface{}) {
 //		logger.Debugf(format, i...)
 //	}
 func DF(ctx context.Context, format string, i ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.DF(ctx, format, i...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Debugf(format, i...)
+// DF see olog.DF
+func (in *Instance) DF(ctx context.Context, format string, i ...interface{}) {
+	l := in.with(ctx)
+	l.Debug(sprintf(format, i))
 }
 
 // W warn
@@ -107,12 +151,13 @@ func DF(ctx context.Context, format string, i ...interface{}) {
 //		logger.Warn(message...)
 //	}
 func W(ctx context.Context, message ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.W(ctx, message...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Warn(message...)
+// W see olog.W
+func (in *Instance) W(ctx context.Context, message ...interface{}) {
+	l := in.with(ctx)
+	l.Warn(sprint(redact(message)...))
 }
 
 // Wf warn with format
@@ -121,22 +166,24 @@ func W(ctx context.Context, message ...interface{}) {
 //		logger.Warnf(format, i...)
 //	}
 func Wf(ctx context.Context, format string, i ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.Wf(ctx, format, i...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Warnf(format, i...)
+// Wf see olog.Wf
+func (in *Instance) Wf(ctx context.Context, format string, i ...interface{}) {
+	l := in.with(ctx)
+	l.Warn(sprintf(format, i))
 }
 
 //	func Panic(i ...interface{}) {
 //		logger.Panic(i)
 //	}
 func Panic(ctx context.Context, i ...interface{}) {
-	if Logger == nil {
-		initLog()
-	}
+	defaultInstance.Panic(ctx, i...)
+}
 
-	l := Logger.With(zap.String("trace-id", getTraceIDFromContext(ctx)), zap.Any("context", ctx))
-	l.Panic(i)
+// Panic see olog.Panic
+func (in *Instance) Panic(ctx context.Context, i ...interface{}) {
+	l := in.with(ctx)
+	l.Panic(sprint(redact(i)))
 }
@@ -3,27 +3,71 @@ package olog
 import (
 	"log"
 	"runtime"
+	"sync"
 
 	"go.opentelemetry.io/contrib/bridges/otelzap"
+	otellog "go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// Logger is created on first use, set it before logging to use your own logger
 var Logger *zap.SugaredLogger
 
+var (
+	loggerOnce sync.Once
+	loggerMu   sync.RWMutex
+	// ownLogger is set when Logger was built by olog, SetLoggerProvider only
+	// replaces such a Logger
+	ownLogger bool
+)
+
+// getLogger initialise Logger once and return it, safe for concurrent use
+func getLogger() *zap.SugaredLogger {
+	loggerOnce.Do(initLog)
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return Logger
+}
+
+// SetLoggerProvider rebuild the Logger of the package functions on provider,
+// nil only writes to stderr. OTel's global logger provider only forwards to
+// the first provider set, so a replaced provider has to be set here too. A
+// Logger set by the user is kept.
+func SetLoggerProvider(provider otellog.LoggerProvider) {
+	loggerOnce.Do(initLog)
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	if ownLogger {
+		Logger = newLogger(provider)
+	}
+}
+
+// Sync flush any buffered log entries
+func Sync() error {
+	return getLogger().Sync()
+}
+
 // initLog logger internal library
 func initLog() {
-	var (
-		logg *zap.Logger
-		err  error
-	)
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
 
 	// if the log is already initialized, do nothing
 	if Logger != nil {
 		return
 	}
 
+	// define logger
+	Logger = newLogger(global.GetLoggerProvider())
+	ownLogger = true
+}
+
+// newLogger build a logger writing JSON to stderr and, when provider is not
+// nil, to the OTel logger provider
+func newLogger(provider otellog.LoggerProvider) *zap.SugaredLogger {
 	cfg := zap.Config{
 		Encoding:         "json",
 		OutputPaths:      []string{"stderr"},
@@ -50,16 +94,19 @@ func initLog() {
 	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
 	cfg.Development = true
 
-	logg, err = cfg.Build()
-	core := zapcore.NewTee(
-		logg.Core(),
-		otelzap.NewCore("OTOOLS-LOG", otelzap.WithLoggerProvider(global.GetLoggerProvider())),
-	)
-	logg = zap.New(core)
+	logg, err := cfg.Build()
 	if err != nil {
 		log.Println(err)
+		logg = zap.NewNop()
 	}
 
-	// define logger
-	Logger = logg.Sugar()
+	if provider != nil {
+		core := zapcore.NewTee(
+			logg.Core(),
+			otelzap.NewCore("OTOOLS-LOG", otelzap.WithLoggerProvider(provider)),
+		)
+		logg = zap.New(core)
+	}
+
+	return logg.Sugar()
 }
@@ -0,0 +1,47 @@
+package olog
+
+import (
+	"sync"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.uber.org/zap"
+)
+
+// Instance is a logger bound to one OTel logger provider, the package
+// functions use a default Instance built on Logger and the global provider
+type Instance struct {
+	get func() *zap.SugaredLogger
+}
+
+var defaultInstance = &Instance{get: getLogger}
+
+// Default get the Instance used by the package functions
+func Default() *Instance {
+	return defaultInstance
+}
+
+// New create an Instance writing to stderr and to provider,
+// provider may be nil to only write to stderr
+func New(provider otellog.LoggerProvider) *Instance {
+	var (
+		once   sync.Once
+		logger *zap.SugaredLogger
+	)
+
+	return &Instance{get: func() *zap.SugaredLogger {
+		once.Do(func() {
+			logger = newLogger(provider)
+		})
+		return logger
+	}}
+}
+
+// Logger get the underlying zap logger
+func (i *Instance) Logger() *zap.SugaredLogger {
+	return i.get()
+}
+
+// Sync flush any buffered log entries
+func (i *Instance) Sync() error {
+	return i.get().Sync()
+}
@@ -0,0 +1,242 @@
+package otrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var (
+	// ErrInvalidEndpoint is returned by Dial and ValidateEndpoint for a
+	// collector address that is not "host:port"
+	ErrInvalidEndpoint = errors.New("invalid collector endpoint")
+	// ErrNotReady is returned by Dial with WithBlock when the collector is not
+	// reachable in time, the returned Conn keeps reconnecting in the background
+	ErrNotReady = errors.New("collector connection not ready")
+)
+
+const (
+	// DefaultBufferSize is the number of export requests kept while the
+	// collector is unreachable
+	DefaultBufferSize = 512
+	// DefaultRetryInterval is how often buffered requests are replayed
+	DefaultRetryInterval = 5 * time.Second
+)
+
+// ValidateEndpoint check hostPort is "host:port" with a port in 1-65535,
+// a "dns:///" prefix is allowed
+func ValidateEndpoint(hostPort string) error {
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(hostPort, "dns:///"))
+	if err != nil {
+		return fmt.Errorf("%w %q: %v", ErrInvalidEndpoint, hostPort, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%w %q: missing host", ErrInvalidEndpoint, hostPort)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("%w %q: invalid port", ErrInvalidEndpoint, hostPort)
+	}
+	return nil
+}
+
+// DialOption configure Dial
+type DialOption func(*dialConfig)
+
+type dialConfig struct {
+	block         time.Duration
+	bufferSize    int
+	retryInterval time.Duration
+	queueDir      string
+	queueMaxBytes int64
+}
+
+// WithBlock wait until the collector is reachable or timeout expires
+func WithBlock(timeout time.Duration) DialOption {
+	return func(c *dialConfig) {
+		c.block = timeout
+	}
+}
+
+// WithBuffer set how many failed export requests are kept for replay,
+// default DefaultBufferSize, maxRequests <= 0 disables buffering
+func WithBuffer(maxRequests int) DialOption {
+	return func(c *dialConfig) {
+		c.bufferSize = maxRequests
+	}
+}
+
+// WithPersistentQueue keep failed export requests in segment files under dir
+// instead of memory, up to maxBytes on disk, so they survive a restart and are
+// replayed once the collector is reachable. Use one dir per signal, a dir
+// holds requests of one signal and is locked by the process using it, Conns of
+// the same process share it. WithBuffer does not apply to the persistent queue.
+func WithPersistentQueue(dir string, maxBytes int64) DialOption {
+	return func(c *dialConfig) {
+		c.queueDir = dir
+		c.queueMaxBytes = maxBytes
+	}
+}
+
+// WithRetryInterval set how often buffered requests are replayed, default
+// DefaultRetryInterval
+func WithRetryInterval(interval time.Duration) DialOption {
+	return func(c *dialConfig) {
+		if interval > 0 {
+			c.retryInterval = interval
+		}
+	}
+}
+
+// Conn is a connection to the collector. Export requests failing because the
+// collector is unreachable are buffered and replayed in the background, so
+// exporters built on it keep working across collector restarts.
+type Conn struct {
+	*grpc.ClientConn
+	queue *retryQueue
+}
+
+// Dial validate hostPort and connect to the collector, see DialOption.
+// With WithBlock a Conn is returned together with ErrNotReady when the
+// collector is not reachable in time.
+func Dial(ctx context.Context, hostPort string, opts ...DialOption) (*Conn, error) {
+	cfg := dialConfig{
+		bufferSize:    DefaultBufferSize,
+		retryInterval: DefaultRetryInterval,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := ValidateEndpoint(hostPort); err != nil {
+		return nil, err
+	}
+
+	c := &Conn{}
+	dialOpts := []grpc.DialOption{
+		// Note the use of insecure transport here. TLS is recommended in production.
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	switch {
+	case cfg.queueDir != "":
+		if cfg.queueMaxBytes <= 0 {
+			return nil, fmt.Errorf("otrace: invalid persistent queue size %d", cfg.queueMaxBytes)
+		}
+		store, err := openDiskStore(cfg.queueDir, cfg.queueMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("otrace: open persistent queue: %w", err)
+		}
+		c.queue = newRetryQueue(store, cfg.retryInterval)
+	case cfg.bufferSize > 0:
+		c.queue = newRetryQueue(&memoryStore{max: cfg.bufferSize}, cfg.retryInterval)
+	}
+	if c.queue != nil {
+		dialOpts = append(dialOpts, grpc.WithUnaryInterceptor(c.queue.intercept))
+	}
+
+	conn, err := grpc.DialContext(ctx, hostPort, dialOpts...)
+	if err != nil {
+		if c.queue != nil {
+			c.queue.stop()
+		}
+		return nil, err
+	}
+	c.ClientConn = conn
+	if c.queue != nil {
+		c.queue.start(conn)
+	}
+
+	if cfg.block > 0 {
+		if err := c.WaitForReady(ctx, cfg.block); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// WaitForReady block until the collector is reachable or timeout expires
+func (c *Conn) WaitForReady(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c.Connect()
+	for {
+		state := c.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !c.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("%w after %s: %s", ErrNotReady, timeout, state)
+		}
+	}
+}
+
+// Pending get the number of items in buffered export requests, see
+// WithExportResult
+func (c *Conn) Pending() int {
+	if c == nil || c.queue == nil {
+		return 0
+	}
+	return c.queue.items()
+}
+
+// Dropped get the number of items in export requests dropped because the
+// buffer was full, a persisted request could not be read back or the
+// collector rejected a replayed request
+func (c *Conn) Dropped() uint64 {
+	if c == nil || c.queue == nil {
+		return 0
+	}
+	return c.queue.dropped.Load()
+}
+
+// ExportResult tell an exporter whether the Conn buffered its request
+// instead of sending it, see WithExportResult
+type ExportResult struct {
+	items    int
+	buffered atomic.Bool
+}
+
+type exportResultKey struct{}
+
+// WithExportResult mark ctx as the context of an export of items spans, data
+// points or log records. The Conn reports on the returned ExportResult when
+// the request is buffered, the exporter returns no error in that case.
+// Requests exported without it count as one item in Pending and Dropped.
+func WithExportResult(ctx context.Context, items int) (context.Context, *ExportResult) {
+	r := &ExportResult{items: items}
+	return context.WithValue(ctx, exportResultKey{}, r), r
+}
+
+// Buffered report whether the request was buffered for replay
+func (r *ExportResult) Buffered() bool {
+	return r != nil && r.buffered.Load()
+}
+
+// requestItems get the items of the request exported with ctx
+func requestItems(ctx context.Context) int {
+	if r, ok := ctx.Value(exportResultKey{}).(*ExportResult); ok {
+		return r.items
+	}
+	return 1
+}
+
+// Close stop replaying and close the connection, buffered requests are sent
+// once more when the collector is reachable
+func (c *Conn) Close() error {
+	if c == nil {
+		return nil
+	}
+	if c.queue != nil {
+		c.queue.stop()
+	}
+	return c.ClientConn.Close()
+}
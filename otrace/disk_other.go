@@ -0,0 +1,16 @@
+//go:build !unix
+
+package otrace
+
+import "os"
+
+// lockDir is only implemented on unix, the queue dir is still not shared
+// within the process
+func lockDir(dir string) (*os.File, error) {
+	return nil, nil
+}
+
+// syncDir is only implemented on unix
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,387 @@
+package otrace
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	segmentExt   = ".seg"
+	segmentMagic = "OTQ1"
+	lockFile     = "LOCK"
+)
+
+var errCorruptSegment = errors.New("corrupt segment")
+
+// openStores are the disk stores open in this process by absolute dir, Conns
+// on the same dir share one store, e.g. while a provider is re-initialised
+var (
+	openStoresMu sync.Mutex
+	openStores   = map[string]*diskStore{}
+)
+
+// diskStore keep every pending request in its own segment file named
+// "<seq>-<items>.seg", written to a temporary file, synced and renamed so a
+// crash never leaves a partial segment. Segments left by a previous process are replayed, the oldest are
+// removed when the total size exceeds maxBytes. The dir is locked against
+// other processes and only holds requests of one gRPC service, i.e. signal.
+type diskStore struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	segments []segment
+	size     int64
+	// itemCount is the items of all segments
+	itemCount int
+	seq       uint64
+	// service of the requests in dir, set by the first segment
+	service string
+
+	// refs and lock are guarded by openStoresMu
+	refs int
+	lock *os.File
+}
+
+type segment struct {
+	name  string
+	size  int64
+	items int
+}
+
+// openDiskStore open the store of dir or share the one already open in this
+// process, close release it
+func openDiskStore(dir string, maxBytes int64) (*diskStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	openStoresMu.Lock()
+	defer openStoresMu.Unlock()
+
+	if s, ok := openStores[dir]; ok {
+		s.refs++
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s, err := loadDiskStore(dir, maxBytes)
+	if err != nil {
+		if lock != nil {
+			lock.Close()
+		}
+		return nil, err
+	}
+	s.refs, s.lock = 1, lock
+	openStores[dir] = s
+	return s, nil
+}
+
+// close release the store, the dir is unlocked once every Conn sharing it
+// closed it
+func (s *diskStore) close() {
+	openStoresMu.Lock()
+	defer openStoresMu.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+	delete(openStores, s.dir)
+	if s.lock != nil {
+		s.lock.Close()
+		s.lock = nil
+	}
+}
+
+// loadDiskStore read the segments left in dir
+func loadDiskStore(dir string, maxBytes int64) (*diskStore, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &diskStore{dir: dir, maxBytes: maxBytes}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, items, err := parseSegmentName(name)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		s.segments = append(s.segments, segment{name: name, size: info.Size(), items: items})
+		s.size += info.Size()
+		s.itemCount += items
+		if seq >= s.seq {
+			s.seq = seq + 1
+		}
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].name < s.segments[j].name })
+	for _, seg := range s.segments {
+		if data, err := os.ReadFile(filepath.Join(dir, seg.name)); err == nil {
+			if method, err := segmentMethod(data); err == nil {
+				s.service = methodService(method)
+				break
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *diskStore) push(p *pendingRequest) (int, error) {
+	data, err := encodeSegment(p.method, p.req)
+	if err != nil {
+		return p.items, err
+	}
+	if int64(len(data)) > s.maxBytes {
+		return p.items, fmt.Errorf("request of %d bytes exceeds queue size %d", len(data), s.maxBytes)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	service := methodService(p.method)
+	if s.service != "" && s.service != service {
+		return p.items, fmt.Errorf("queue dir %s holds %s requests, got %s, use one dir per signal", s.dir, s.service, service)
+	}
+	s.service = service
+
+	name := fmt.Sprintf("%020d-%d%s", s.seq, p.items, segmentExt)
+	s.seq++
+	if err := writeFileSync(filepath.Join(s.dir, name), data); err != nil {
+		return p.items, err
+	}
+	s.segments = append(s.segments, segment{name: name, size: int64(len(data)), items: p.items})
+	s.size += int64(len(data))
+	s.itemCount += p.items
+
+	dropped := 0
+	for s.size > s.maxBytes && len(s.segments) > 1 {
+		dropped += s.removeOldest()
+	}
+	return dropped, nil
+}
+
+// peek read the oldest segment, unreadable segments are removed and counted
+// as dropped
+func (s *diskStore) peek() (*pendingRequest, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dropped := 0
+	for len(s.segments) > 0 {
+		seg := s.segments[0]
+		data, err := os.ReadFile(filepath.Join(s.dir, seg.name))
+		if err == nil {
+			var p *pendingRequest
+			if p, err = decodeSegment(data); err == nil {
+				p.segment, p.items = seg.name, seg.items
+				return p, dropped
+			}
+		}
+		dropped += s.removeOldest()
+	}
+	return nil, dropped
+}
+
+func (s *diskStore) remove(p *pendingRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) > 0 && s.segments[0].name == p.segment {
+		s.removeOldest()
+	}
+}
+
+func (s *diskStore) items() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.itemCount
+}
+
+// removeOldest delete the oldest segment and get its items, mu must be held
+func (s *diskStore) removeOldest() int {
+	oldest := s.segments[0]
+	os.Remove(filepath.Join(s.dir, oldest.name))
+	s.segments = s.segments[1:]
+	s.size -= oldest.size
+	s.itemCount -= oldest.items
+	return oldest.items
+}
+
+// parseSegmentName get the sequence number and items of a segment file
+func parseSegmentName(name string) (uint64, int, error) {
+	seq, items, ok := strings.Cut(strings.TrimSuffix(name, segmentExt), "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid segment name %q", name)
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	i, err := strconv.Atoi(items)
+	if err != nil || i < 0 {
+		return 0, 0, fmt.Errorf("invalid segment name %q", name)
+	}
+	return n, i, nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// the rename is only durable once the dir is synced
+	return syncDir(filepath.Dir(path))
+}
+
+// encodeSegment layout: magic, uint16 method length, method, uint32 payload
+// length, payload, uint32 CRC-32 of everything before it
+func encodeSegment(method string, req proto.Message) ([]byte, error) {
+	payload, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(segmentMagic)+2+len(method)+4+len(payload)+4)
+	b = append(b, segmentMagic...)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(method)))
+	b = append(b, method...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(payload)))
+	b = append(b, payload...)
+	b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+	return b, nil
+}
+
+func decodeSegment(b []byte) (*pendingRequest, error) {
+	method, payload, err := splitSegment(b)
+	if err != nil {
+		return nil, err
+	}
+
+	reqType, replyType, err := methodTypes(method)
+	if err != nil {
+		return nil, err
+	}
+	req := reqType.New().Interface()
+	if err := proto.Unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	return &pendingRequest{method: method, req: req, reply: replyType.New().Interface()}, nil
+}
+
+// segmentMethod get the method of a segment without decoding the request
+func segmentMethod(b []byte) (string, error) {
+	method, _, err := splitSegment(b)
+	return method, err
+}
+
+// splitSegment check the segment and get its method and payload
+func splitSegment(b []byte) (string, []byte, error) {
+	if len(b) < len(segmentMagic)+2+4+4 || string(b[:len(segmentMagic)]) != segmentMagic {
+		return "", nil, errCorruptSegment
+	}
+	body, sum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return "", nil, errCorruptSegment
+	}
+
+	rest := body[len(segmentMagic):]
+	methodLen := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < methodLen+4 {
+		return "", nil, errCorruptSegment
+	}
+	method := string(rest[:methodLen])
+	rest = rest[methodLen:]
+	payloadLen := int(binary.BigEndian.Uint32(rest))
+	rest = rest[4:]
+	if len(rest) != payloadLen {
+		return "", nil, errCorruptSegment
+	}
+	return method, rest, nil
+}
+
+// methodService get the service of a gRPC method, e.g.
+// "opentelemetry.proto.collector.trace.v1.TraceService"
+func methodService(method string) string {
+	service, _, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return service
+}
+
+// methodTypes find the request and reply types of a gRPC method like
+// "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+func methodTypes(method string) (protoreflect.MessageType, protoreflect.MessageType, error) {
+	service, name, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid method %q", method)
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, nil, err
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(name))
+	if md == nil {
+		return nil, nil, fmt.Errorf("unknown method %q", method)
+	}
+
+	reqType, err := protoregistry.GlobalTypes.FindMessageByName(md.Input().FullName())
+	if err != nil {
+		return nil, nil, err
+	}
+	replyType, err := protoregistry.GlobalTypes.FindMessageByName(md.Output().FullName())
+	if err != nil {
+		return nil, nil, err
+	}
+	return reqType, replyType, nil
+}
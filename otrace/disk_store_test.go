@@ -0,0 +1,248 @@
+package otrace
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	traceMethod = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	logsMethod  = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+)
+
+func traceRequest(name string) *pendingRequest {
+	return &pendingRequest{
+		method: traceMethod,
+		items:  1,
+		req: &coltrace.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+			ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: name}}}},
+		}}},
+	}
+}
+
+func spanName(t *testing.T, p *pendingRequest) string {
+	t.Helper()
+	req, ok := p.req.(*coltrace.ExportTraceServiceRequest)
+	if !ok {
+		t.Fatalf("got request %T", p.req)
+	}
+	return req.ResourceSpans[0].ScopeSpans[0].Spans[0].Name
+}
+
+// withCRC replace the checksum of b after it was modified
+func withCRC(b []byte) []byte {
+	body := b[:len(b)-4]
+	return binary.BigEndian.AppendUint32(append([]byte(nil), body...), crc32.ChecksumIEEE(body))
+}
+
+func TestDecodeSegment(t *testing.T) {
+	valid, err := encodeSegment(traceMethod, traceRequest("op").req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := encodeSegment("/unknown.Service/Export", traceRequest("op").req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "valid", data: valid},
+		{name: "empty", data: nil, wantErr: true},
+		{name: "bad magic", data: append([]byte("XXXX"), valid[4:]...), wantErr: true},
+		{name: "truncated", data: valid[:len(valid)-3], wantErr: true},
+		{name: "truncated header", data: valid[:9], wantErr: true},
+		{name: "flipped payload bit", data: func() []byte {
+			b := append([]byte(nil), valid...)
+			b[len(b)-6] ^= 0x01
+			return b
+		}(), wantErr: true},
+		{name: "method length past the end", data: func() []byte {
+			b := append([]byte(nil), valid...)
+			binary.BigEndian.PutUint16(b[4:], 0xffff)
+			return withCRC(b)
+		}(), wantErr: true},
+		{name: "payload length mismatch", data: func() []byte {
+			b := append([]byte(nil), valid...)
+			off := 4 + 2 + len(traceMethod)
+			binary.BigEndian.PutUint32(b[off:], binary.BigEndian.Uint32(b[off:])+1)
+			return withCRC(b)
+		}(), wantErr: true},
+		{name: "unknown method", data: unknown, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := decodeSegment(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.method != traceMethod || spanName(t, p) != "op" {
+				t.Fatalf("got %s %v", p.method, p.req)
+			}
+			if _, ok := p.reply.(*coltrace.ExportTraceServiceResponse); !ok {
+				t.Fatalf("got reply %T", p.reply)
+			}
+		})
+	}
+}
+
+func openTestStore(t *testing.T, dir string, maxBytes int64) *diskStore {
+	t.Helper()
+	s, err := openDiskStore(dir, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDiskStoreReplayAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, 1<<20)
+	for _, name := range []string{"a", "b", "c"} {
+		if dropped, err := s.push(traceRequest(name)); err != nil || dropped != 0 {
+			t.Fatalf("push %s: dropped %d, %v", name, dropped, err)
+		}
+	}
+	p, _ := s.peek()
+	s.remove(p)
+	s.close()
+
+	// a partial write of a crashed process is ignored
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000009.seg.tmp"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestStore(t, dir, 1<<20)
+	defer s.close()
+	if s.items() != 2 {
+		t.Fatalf("got %d items, want 2", s.items())
+	}
+	for _, want := range []string{"b", "c"} {
+		p, dropped := s.peek()
+		if p == nil || dropped != 0 {
+			t.Fatalf("peek: %v, dropped %d", p, dropped)
+		}
+		if got := spanName(t, p); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+		s.remove(p)
+	}
+	if _, err := s.push(traceRequest("d")); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.peek(); p == nil || spanName(t, p) != "d" {
+		t.Fatal("want the new segment after the replayed ones")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "00000000000000000009.seg.tmp")); !os.IsNotExist(err) {
+		t.Fatal("temporary file was not removed")
+	}
+}
+
+func TestDiskStoreCorruptSegmentDropped(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, 1<<20)
+	s.push(traceRequest("a"))
+	s.push(traceRequest("b"))
+	s.close()
+
+	first := filepath.Join(dir, "00000000000000000000-1.seg")
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(first, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestStore(t, dir, 1<<20)
+	defer s.close()
+	p, dropped := s.peek()
+	if dropped != 1 || p == nil || spanName(t, p) != "b" {
+		t.Fatalf("got %v dropped %d, want b after dropping the corrupt segment", p, dropped)
+	}
+}
+
+func TestDiskStoreSizeBound(t *testing.T) {
+	data, err := encodeSegment(traceMethod, traceRequest("a").req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := openTestStore(t, t.TempDir(), int64(2*len(data)))
+	defer s.close()
+
+	dropped := 0
+	for _, name := range []string{"a", "b", "c"} {
+		n, err := s.push(traceRequest(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dropped += n
+	}
+	if dropped != 1 || s.items() != 2 {
+		t.Fatalf("dropped %d, kept %d, want the oldest dropped", dropped, s.items())
+	}
+	if p, _ := s.peek(); p == nil || spanName(t, p) != "b" {
+		t.Fatal("want b as oldest segment")
+	}
+
+	big := traceRequest(strings.Repeat("x", 4*len(data)))
+	if n, err := s.push(big); err == nil || n != 1 {
+		t.Fatalf("got dropped %d, %v, want a request over the size rejected", n, err)
+	}
+}
+
+func TestDiskStoreOneSignalPerDir(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, 1<<20)
+	if _, err := s.push(traceRequest("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a Conn of the same process shares the store
+	shared := openTestStore(t, dir, 1<<20)
+	if shared != s {
+		t.Fatal("want the open store shared")
+	}
+	logs := &pendingRequest{method: logsMethod, req: &collogs.ExportLogsServiceRequest{}, items: 1}
+	if _, err := shared.push(logs); err == nil {
+		t.Fatal("want logs rejected by a dir holding traces")
+	}
+	shared.close()
+	s.close()
+
+	// the service is kept across restarts
+	s = openTestStore(t, dir, 1<<20)
+	defer s.close()
+	if _, err := s.push(logs); err == nil {
+		t.Fatal("want logs rejected after reopening a dir holding traces")
+	}
+}
+
+func TestDiskStoreCloseReleasesDir(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, 1<<20)
+	s.close()
+
+	reopened := openTestStore(t, dir, 1<<20)
+	defer reopened.close()
+	if reopened == s {
+		t.Fatal("want a new store once the previous one is closed")
+	}
+}
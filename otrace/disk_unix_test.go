@@ -0,0 +1,23 @@
+//go:build unix
+
+package otrace
+
+import "testing"
+
+func TestLockDirOtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, 1<<20)
+
+	// a second lock on the dir behaves like another process
+	if f, err := lockDir(dir); err == nil {
+		f.Close()
+		t.Fatal("want the dir locked while the store is open")
+	}
+	s.close()
+
+	f, err := lockDir(dir)
+	if err != nil {
+		t.Fatalf("want the dir unlocked once the store is closed: %v", err)
+	}
+	f.Close()
+}
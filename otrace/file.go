@@ -0,0 +1,117 @@
+package otrace
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ownedFile is a file appended to by one exporter and closed by its Shutdown
+type ownedFile struct {
+	*os.File
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func openOwnedFile(path string) (*ownedFile, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &ownedFile{File: f}, nil
+}
+
+func (f *ownedFile) close() error {
+	f.closeOnce.Do(func() {
+		f.closeErr = f.File.Close()
+	})
+	return f.closeErr
+}
+
+// FileSpanExporter write spans as JSON to a file it owns, Shutdown close the
+// file
+type FileSpanExporter struct {
+	trace.SpanExporter
+	file *ownedFile
+}
+
+// NewFileSpanExporter append spans to path, e.g. "traces.json"
+func NewFileSpanExporter(path string) (*FileSpanExporter, error) {
+	f, err := openOwnedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := NewExporterTraceFile(f)
+	if err != nil {
+		return nil, errors.Join(err, f.close())
+	}
+	return &FileSpanExporter{SpanExporter: exp, file: f}, nil
+}
+
+// Shutdown the exporter and close the file
+func (e *FileSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.close())
+}
+
+// FileMetricExporter write metrics to a file it owns, Shutdown close the file
+type FileMetricExporter struct {
+	metric.Exporter
+	file *ownedFile
+}
+
+// NewFileMetricExporter append metrics to path, e.g. "metric.json". newEncoder
+// create the encoder writing to the file, nil writes indented JSON.
+func NewFileMetricExporter(path string, newEncoder func(io.Writer) stdoutmetric.Encoder, opts ...stdoutmetric.Option) (*FileMetricExporter, error) {
+	f, err := openOwnedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if newEncoder != nil {
+		opts = append([]stdoutmetric.Option{stdoutmetric.WithEncoder(newEncoder(f))}, opts...)
+	} else {
+		opts = append([]stdoutmetric.Option{stdoutmetric.WithWriter(f), stdoutmetric.WithPrettyPrint()}, opts...)
+	}
+	exp, err := stdoutmetric.New(opts...)
+	if err != nil {
+		return nil, errors.Join(err, f.close())
+	}
+	return &FileMetricExporter{Exporter: exp, file: f}, nil
+}
+
+// Shutdown the exporter and close the file
+func (e *FileMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.file.close())
+}
+
+// FileLogExporter write log records as JSON to a file it owns, Shutdown close
+// the file
+type FileLogExporter struct {
+	log.Exporter
+	file *ownedFile
+}
+
+// NewFileLogExporter append log records to path, e.g. "log.json"
+func NewFileLogExporter(path string) (*FileLogExporter, error) {
+	f, err := openOwnedFile(path)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := stdoutlog.New(stdoutlog.WithWriter(f))
+	if err != nil {
+		return nil, errors.Join(err, f.close())
+	}
+	return &FileLogExporter{Exporter: exp, file: f}, nil
+}
+
+// Shutdown the exporter and close the file
+func (e *FileLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.file.close())
+}
@@ -23,6 +23,8 @@ type NewRequest struct {
 	SpanID  string
 }
 
+// NewGrpcConn returns nil when the connection can not be created, use Dial
+// to get the error, endpoint validation and buffering
 func NewGrpcConn(ctx context.Context, hostPort string) *grpc.ClientConn {
 	conn, err := grpc.DialContext(ctx, hostPort,
 		// Note the use of insecure transport here. TLS is recommended in production.
@@ -55,8 +57,8 @@ func NewExporterTraceFile(w io.Writer) (trace.SpanExporter, error) {
 	)
 }
 
-func NewExporterMetricGRPC(ctx context.Context, conn *grpc.ClientConn) (metric.Exporter, error) {
-	return otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
+func NewExporterMetricGRPC(ctx context.Context, conn *grpc.ClientConn, opts ...otlpmetricgrpc.Option) (metric.Exporter, error) {
+	return otlpmetricgrpc.New(ctx, append([]otlpmetricgrpc.Option{otlpmetricgrpc.WithGRPCConn(conn)}, opts...)...)
 }
 
 func NewExporterMetricHttp(ctx context.Context, hostPort string) (metric.Exporter, error) {
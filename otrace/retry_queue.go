@@ -0,0 +1,262 @@
+package otrace
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// replayTimeout bound one replayed request
+const replayTimeout = 10 * time.Second
+
+// replayKey mark the context of replayed requests so they are not buffered twice
+type replayKey struct{}
+
+type pendingRequest struct {
+	method string
+	req    proto.Message
+	reply  proto.Message
+	// items is the number of spans, data points or log records of req
+	items int
+
+	// segment is the file of a request kept by diskStore
+	segment string
+}
+
+// requestStore keep pending requests in order, push return how many items
+// were dropped to stay within its bound
+type requestStore interface {
+	push(p *pendingRequest) (dropped int, err error)
+	peek() (p *pendingRequest, dropped int)
+	remove(p *pendingRequest)
+	// items get the items of the pending requests
+	items() int
+	// close release the store once the queue stopped
+	close()
+}
+
+// retryQueue is a unary interceptor keeping export requests that failed with
+// a retryable code, they are replayed by a background goroutine
+type retryQueue struct {
+	store    requestStore
+	interval time.Duration
+	dropped  atomic.Uint64
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	done     chan struct{}
+	conn     *grpc.ClientConn
+}
+
+func newRetryQueue(store requestStore, interval time.Duration) *retryQueue {
+	return &retryQueue{
+		store:    store,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (q *retryQueue) intercept(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil || !retryable(err) || ctx.Value(replayKey{}) != nil {
+		return err
+	}
+
+	reqMsg, ok := req.(proto.Message)
+	if !ok {
+		return err
+	}
+	replyMsg, ok := reply.(proto.Message)
+	if !ok {
+		return err
+	}
+	q.push(&pendingRequest{method: method, req: proto.Clone(reqMsg), reply: proto.Clone(replyMsg), items: requestItems(ctx)})
+	if r, ok := ctx.Value(exportResultKey{}).(*ExportResult); ok {
+		r.buffered.Store(true)
+	}
+	return nil
+}
+
+// retryable report codes the OTLP spec allows to retry
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// push store p, the oldest requests are dropped when the store is full
+func (q *retryQueue) push(p *pendingRequest) {
+	dropped, err := q.store.push(p)
+	if err != nil {
+		otel.Handle(fmt.Errorf("otrace: buffer export request: %w", err))
+	}
+	q.addDropped(dropped)
+}
+
+func (q *retryQueue) peek() *pendingRequest {
+	p, dropped := q.store.peek()
+	q.addDropped(dropped)
+	return p
+}
+
+func (q *retryQueue) addDropped(dropped int) {
+	if dropped > 0 {
+		total := q.dropped.Add(uint64(dropped))
+		otel.Handle(fmt.Errorf("otrace: export queue dropped %d items in total", total))
+	}
+}
+
+func (q *retryQueue) items() int {
+	return q.store.items()
+}
+
+// memoryStore keep up to max requests in memory
+type memoryStore struct {
+	mu       sync.Mutex
+	requests []*pendingRequest
+	size     int
+	max      int
+}
+
+func (s *memoryStore) push(p *pendingRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, p)
+	s.size += p.items
+	dropped := 0
+	for len(s.requests) > s.max {
+		dropped += s.removeOldest()
+	}
+	return dropped, nil
+}
+
+func (s *memoryStore) peek() (*pendingRequest, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.requests) == 0 {
+		return nil, 0
+	}
+	return s.requests[0], 0
+}
+
+// remove p once it is sent, it can already be dropped by push
+func (s *memoryStore) remove(p *pendingRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.requests) > 0 && s.requests[0] == p {
+		s.removeOldest()
+	}
+}
+
+// removeOldest remove the oldest request and get its items, mu must be held
+func (s *memoryStore) removeOldest() int {
+	items := s.requests[0].items
+	s.requests[0] = nil
+	s.requests = s.requests[1:]
+	s.size -= items
+	return items
+}
+
+func (s *memoryStore) items() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+func (s *memoryStore) close() {}
+
+func (q *retryQueue) start(conn *grpc.ClientConn) {
+	q.conn = conn
+	go q.run()
+}
+
+func (q *retryQueue) run() {
+	defer close(q.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{}, 1)
+	go q.watchReady(ctx, ready)
+
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stopCh:
+			return
+		case <-ticker.C:
+			q.replay()
+		case <-ready:
+			q.replay()
+		}
+	}
+}
+
+// watchReady notify ready every time the connection becomes ready, so requests
+// are replayed on reconnect without waiting for the ticker
+func (q *retryQueue) watchReady(ctx context.Context, ready chan<- struct{}) {
+	state := q.conn.GetState()
+	for q.conn.WaitForStateChange(ctx, state) {
+		state = q.conn.GetState()
+		if state == connectivity.Ready {
+			select {
+			case ready <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// replay send buffered requests in order until one fails with a retryable
+// code, requests rejected by the collector for other reasons are dropped and
+// counted
+func (q *retryQueue) replay() {
+	for {
+		p := q.peek()
+		if p == nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), replayKey{}, true), replayTimeout)
+		err := q.conn.Invoke(ctx, p.method, p.req, proto.Clone(p.reply))
+		cancel()
+		if err != nil && retryable(err) {
+			return
+		}
+		q.store.remove(p)
+		if err != nil {
+			q.addDropped(p.items)
+		}
+	}
+}
+
+// stop the replay goroutine, replay once more when the collector is reachable
+// and close the store
+func (q *retryQueue) stop() {
+	q.stopOnce.Do(func() {
+		close(q.stopCh)
+		if q.conn != nil {
+			<-q.done
+			if q.conn.GetState() == connectivity.Ready {
+				q.replay()
+			}
+		}
+		q.store.close()
+	})
+}
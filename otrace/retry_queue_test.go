@@ -0,0 +1,65 @@
+package otrace
+
+import (
+	"context"
+	"testing"
+
+	coltrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryQueueCountsItems(t *testing.T) {
+	stores := map[string]func(t *testing.T) requestStore{
+		"memory": func(t *testing.T) requestStore { return &memoryStore{max: 2} },
+		"disk": func(t *testing.T) requestStore {
+			// room for two requests of this test
+			data, err := encodeSegment(traceMethod, traceRequest("a").req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return openTestStore(t, t.TempDir(), int64(2*len(data)))
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			q := newRetryQueue(newStore(t), DefaultRetryInterval)
+			defer q.store.close()
+
+			invokerErr := status.Error(codes.Unavailable, "collector down")
+			invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				return invokerErr
+			}
+			export := func(ctx context.Context, span string) error {
+				return q.intercept(ctx, traceMethod, traceRequest(span).req, &coltrace.ExportTraceServiceResponse{}, nil, invoker)
+			}
+
+			ctx, result := WithExportResult(context.Background(), 3)
+			if err := export(ctx, "a"); err != nil || !result.Buffered() {
+				t.Fatalf("got %v, buffered %v, want the request buffered", err, result.Buffered())
+			}
+			// without an ExportResult a request counts as one item
+			if err := export(context.Background(), "b"); err != nil {
+				t.Fatal(err)
+			}
+			if got := q.items(); got != 4 {
+				t.Fatalf("got %d pending items, want 4", got)
+			}
+
+			ctx, result = WithExportResult(context.Background(), 5)
+			if err := export(ctx, "c"); err != nil || !result.Buffered() {
+				t.Fatalf("got %v, buffered %v, want the request buffered", err, result.Buffered())
+			}
+			if got, dropped := q.items(), q.dropped.Load(); got != 6 || dropped != 3 {
+				t.Fatalf("got %d pending, %d dropped items, want 6 pending and the 3 items of a dropped", got, dropped)
+			}
+
+			invokerErr = status.Error(codes.InvalidArgument, "bad request")
+			ctx, result = WithExportResult(context.Background(), 7)
+			if err := export(ctx, "d"); err == nil || result.Buffered() {
+				t.Fatalf("got %v, buffered %v, want a rejected request returned", err, result.Buffered())
+			}
+		})
+	}
+}
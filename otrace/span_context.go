@@ -0,0 +1,90 @@
+package otrace
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rudiarta/otools/outils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxIDLength bound the client supplied value quoted in errors
+const maxIDLength = 128
+
+var (
+	ErrInvalidTraceID     = errors.New("invalid trace id")
+	ErrInvalidSpanID      = errors.New("invalid span id")
+	ErrInvalidTraceparent = errors.New("invalid traceparent")
+)
+
+// NewSpanContext build a sampled remote span context from hex trace & span ID
+func NewSpanContext(request NewRequest) (trace.SpanContext, error) {
+	traceID, err := trace.TraceIDFromHex(request.TraceID)
+	if err != nil {
+		return trace.SpanContext{}, fmt.Errorf("%w %q: %s", ErrInvalidTraceID, outils.Truncate(request.TraceID, maxIDLength), err.Error())
+	}
+	spanID, err := trace.SpanIDFromHex(request.SpanID)
+	if err != nil {
+		return trace.SpanContext{}, fmt.Errorf("%w %q: %s", ErrInvalidSpanID, outils.Truncate(request.SpanID, maxIDLength), err.Error())
+	}
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}), nil
+}
+
+// ParseTraceparent parse a W3C traceparent header,
+// Ex: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func ParseTraceparent(traceparent string) (trace.SpanContext, error) {
+	invalid := func(reason string) (trace.SpanContext, error) {
+		return trace.SpanContext{}, fmt.Errorf("%w %q: %s", ErrInvalidTraceparent, outils.Truncate(traceparent, maxIDLength), reason)
+	}
+
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return invalid("expected version-traceid-parentid-flags")
+	}
+
+	version := parts[0]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return invalid("bad version")
+	}
+	if version == "00" && len(parts) != 4 {
+		return invalid("version 00 must have 4 fields")
+	}
+
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return invalid("bad trace id: " + err.Error())
+	}
+	spanID, err := trace.SpanIDFromHex(parts[2])
+	if err != nil {
+		return invalid("bad parent id: " + err.Error())
+	}
+
+	if len(parts[3]) != 2 || !isLowerHex(parts[3]) {
+		return invalid("bad flags")
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(flags) & trace.FlagsSampled,
+		Remote:     true,
+	}), nil
+}
+
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package otrace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	validTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	validSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{name: "sampled", traceparent: "00-" + validTraceID + "-" + validSpanID + "-01", wantSampled: true},
+		{name: "not sampled", traceparent: "00-" + validTraceID + "-" + validSpanID + "-00"},
+		{name: "unknown flags are dropped", traceparent: "00-" + validTraceID + "-" + validSpanID + "-03", wantSampled: true},
+		{name: "surrounding spaces", traceparent: " 00-" + validTraceID + "-" + validSpanID + "-01 ", wantSampled: true},
+		{name: "future version with more fields", traceparent: "01-" + validTraceID + "-" + validSpanID + "-01-extra", wantSampled: true},
+		{name: "empty", traceparent: "", wantErr: true},
+		{name: "missing fields", traceparent: "00-" + validTraceID + "-" + validSpanID, wantErr: true},
+		{name: "version 00 with more fields", traceparent: "00-" + validTraceID + "-" + validSpanID + "-01-extra", wantErr: true},
+		{name: "version ff", traceparent: "ff-" + validTraceID + "-" + validSpanID + "-01", wantErr: true},
+		{name: "short version", traceparent: "0-" + validTraceID + "-" + validSpanID + "-01", wantErr: true},
+		{name: "uppercase version", traceparent: "0A-" + validTraceID + "-" + validSpanID + "-01", wantErr: true},
+		{name: "uppercase trace id", traceparent: "00-" + strings.ToUpper(validTraceID) + "-" + validSpanID + "-01", wantErr: true},
+		{name: "uppercase span id", traceparent: "00-" + validTraceID + "-" + strings.ToUpper(validSpanID) + "-01", wantErr: true},
+		{name: "uppercase flags", traceparent: "00-" + validTraceID + "-" + validSpanID + "-0A", wantErr: true},
+		{name: "short trace id", traceparent: "00-" + validTraceID[1:] + "-" + validSpanID + "-01", wantErr: true},
+		{name: "long span id", traceparent: "00-" + validTraceID + "-" + validSpanID + "0-01", wantErr: true},
+		{name: "zero trace id", traceparent: "00-" + strings.Repeat("0", 32) + "-" + validSpanID + "-01", wantErr: true},
+		{name: "zero span id", traceparent: "00-" + validTraceID + "-" + strings.Repeat("0", 16) + "-01", wantErr: true},
+		{name: "non hex trace id", traceparent: "00-" + strings.Repeat("g", 32) + "-" + validSpanID + "-01", wantErr: true},
+		{name: "three digit flags", traceparent: "00-" + validTraceID + "-" + validSpanID + "-001", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.traceparent)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTraceparent) {
+					t.Fatalf("got %v, want ErrInvalidTraceparent", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.TraceID().String() != validTraceID || sc.SpanID().String() != validSpanID {
+				t.Fatalf("got %s-%s", sc.TraceID(), sc.SpanID())
+			}
+			if !sc.IsRemote() || sc.IsSampled() != tt.wantSampled {
+				t.Fatalf("got remote %t sampled %t, want sampled %t", sc.IsRemote(), sc.IsSampled(), tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestNewSpanContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		request NewRequest
+		wantErr error
+	}{
+		{name: "valid", request: NewRequest{TraceID: validTraceID, SpanID: validSpanID}},
+		{name: "empty trace id", request: NewRequest{SpanID: validSpanID}, wantErr: ErrInvalidTraceID},
+		{name: "uppercase trace id", request: NewRequest{TraceID: strings.ToUpper(validTraceID), SpanID: validSpanID}, wantErr: ErrInvalidTraceID},
+		{name: "bad span id", request: NewRequest{TraceID: validTraceID, SpanID: "xyz"}, wantErr: ErrInvalidSpanID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := NewSpanContext(tt.request)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sc.IsValid() || !sc.IsRemote() || sc.TraceFlags() != trace.FlagsSampled {
+				t.Fatalf("got %+v", sc)
+			}
+		})
+	}
+}
+
+func TestInvalidIDErrorTruncated(t *testing.T) {
+	long := strings.Repeat("a", 10_000)
+
+	_, err := ParseTraceparent("00-" + long + "-" + validSpanID + "-01")
+	if err == nil || len(err.Error()) > 2*maxIDLength+100 {
+		t.Fatalf("traceparent error of %d bytes is not truncated", len(err.Error()))
+	}
+	_, err = NewSpanContext(NewRequest{TraceID: long, SpanID: validSpanID})
+	if err == nil || len(err.Error()) > 2*maxIDLength+100 {
+		t.Fatalf("trace id error of %d bytes is not truncated", len(err.Error()))
+	}
+	_, err = NewSpanContext(NewRequest{TraceID: validTraceID, SpanID: long})
+	if err == nil || len(err.Error()) > 2*maxIDLength+100 {
+		t.Fatalf("span id error of %d bytes is not truncated", len(err.Error()))
+	}
+}
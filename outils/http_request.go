@@ -35,6 +35,26 @@ type HttpRequestStatistic struct {
 	DurationTotalHTTPRequest time.Duration
 }
 
+// RedactedHeader returns the request headers with sensitive values masked,
+// use it instead of Header when logging or tracing the request.
+func (p *HttpRequestParams) RedactedHeader() map[string]string {
+	if p.Header == nil {
+		return nil
+	}
+
+	header := make(map[string]string, len(p.Header))
+	for key, value := range p.Header {
+		header[key] = ToString(RedactKeyValue(key, value))
+	}
+	return header
+}
+
+// RedactedHeader returns the response headers with sensitive values masked,
+// use it instead of Header when logging or tracing the response.
+func (r *HttpRequestResponse) RedactedHeader() http.Header {
+	return RedactHeader(r.Header)
+}
+
 // HTTPRequestJSON ...
 func HTTPRequestJSON(params *HttpRequestParams) (*HttpRequestResponse, error) {
 	req, err := http.NewRequestWithContext(params.Context, params.Method, params.URL, params.Body)
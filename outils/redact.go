@@ -0,0 +1,459 @@
+package outils
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RedactedValue replaces anything the redactor considers sensitive.
+const RedactedValue = "[REDACTED]"
+
+// redactTag is the struct tag used to mark a field as sensitive, e.g.
+//
+//	Password string `json:"password" otools:"redact"`
+const redactTag = "otools"
+
+const maxRedactDepth = 10
+
+var (
+	// DefaultRedactKeys are the keys masked by the default redactor, matched
+	// case-insensitively after removing "-", "_" and "." separators.
+	DefaultRedactKeys = []string{
+		"password", "passwd", "pwd", "secret", "clientsecret",
+		"token", "accesstoken", "refreshtoken", "idtoken", "apikey", "xapikey",
+		"authorization", "proxyauthorization", "cookie", "setcookie",
+		"cardnumber", "creditcard", "pan", "cvv", "cvc", "pin",
+	}
+
+	// DefaultRedactPatterns are the value patterns scrubbed by the default redactor.
+	DefaultRedactPatterns = []*regexp.Regexp{
+		// e-mail addresses
+		regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		// bearer / basic credentials
+		regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`),
+		// JSON web tokens
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`),
+	}
+
+	// cardNumberPattern matches 13-19 digits optionally grouped by spaces or
+	// dashes, candidates are only redacted when they start with a known issuer
+	// prefix, have a length the issuer uses and pass the Luhn check.
+	cardNumberPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+
+	keyReplacer = strings.NewReplacer("-", "", "_", "", ".", "", " ", "")
+)
+
+// Redactor masks sensitive keys and scrubs sensitive values out of
+// log fields, span tags, metric attributes and HTTP headers.
+type Redactor struct {
+	keys        map[string]struct{}
+	patterns    []*regexp.Regexp
+	cardNumbers bool
+	mask        string
+}
+
+// RedactOption configures a Redactor.
+type RedactOption func(*Redactor)
+
+// WithRedactKeys adds keys to the denylist.
+func WithRedactKeys(keys ...string) RedactOption {
+	return func(r *Redactor) {
+		for _, k := range keys {
+			r.keys[normalizeKey(k)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactPatterns adds value patterns to be scrubbed.
+func WithRedactPatterns(patterns ...*regexp.Regexp) RedactOption {
+	return func(r *Redactor) {
+		r.patterns = append(r.patterns, patterns...)
+	}
+}
+
+// WithRedactMask sets the replacement text, default is RedactedValue.
+func WithRedactMask(mask string) RedactOption {
+	return func(r *Redactor) {
+		r.mask = mask
+	}
+}
+
+// WithoutDefaultRedactRules starts from an empty denylist and pattern list.
+func WithoutDefaultRedactRules() RedactOption {
+	return func(r *Redactor) {
+		r.keys = make(map[string]struct{})
+		r.patterns = nil
+		r.cardNumbers = false
+	}
+}
+
+// NewRedactor returns a Redactor using DefaultRedactKeys,
+// DefaultRedactPatterns and card number detection plus the given options.
+func NewRedactor(opts ...RedactOption) *Redactor {
+	r := &Redactor{
+		keys:        make(map[string]struct{}, len(DefaultRedactKeys)),
+		patterns:    append([]*regexp.Regexp(nil), DefaultRedactPatterns...),
+		cardNumbers: true,
+		mask:        RedactedValue,
+	}
+	for _, k := range DefaultRedactKeys {
+		r.keys[normalizeKey(k)] = struct{}{}
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+var (
+	redactorMu      sync.RWMutex
+	defaultRedactor = NewRedactor()
+)
+
+// SetRedactor replaces the redactor used by otools, nil disables redaction.
+func SetRedactor(r *Redactor) {
+	redactorMu.Lock()
+	defaultRedactor = r
+	redactorMu.Unlock()
+}
+
+// GetRedactor returns the redactor used by otools, it may be nil.
+func GetRedactor() *Redactor {
+	redactorMu.RLock()
+	defer redactorMu.RUnlock()
+	return defaultRedactor
+}
+
+// Redact redacts v with the default redactor.
+func Redact(v interface{}) interface{} {
+	return GetRedactor().Redact(v)
+}
+
+// RedactString scrubs s with the default redactor.
+func RedactString(s string) string {
+	return GetRedactor().RedactString(s)
+}
+
+// RedactKeyValue redacts a single key/value pair with the default redactor.
+func RedactKeyValue(key string, v interface{}) interface{} {
+	return GetRedactor().RedactKeyValue(key, v)
+}
+
+// RedactHeader returns a redacted copy of h using the default redactor.
+func RedactHeader(h http.Header) http.Header {
+	return GetRedactor().RedactHeader(h)
+}
+
+// RedactAttributes returns a redacted copy of attrs using the default redactor.
+func RedactAttributes(attrs ...attribute.KeyValue) []attribute.KeyValue {
+	return GetRedactor().RedactAttributes(attrs...)
+}
+
+// IsSensitiveKey reports whether key is in the default denylist.
+func IsSensitiveKey(key string) bool {
+	return GetRedactor().IsSensitiveKey(key)
+}
+
+// IsSensitiveKey reports whether key is in the denylist.
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.keys[normalizeKey(key)]
+	return ok
+}
+
+// RedactString replaces every match of the value patterns in s.
+func (r *Redactor) RedactString(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, r.mask)
+	}
+	if r.cardNumbers {
+		s = cardNumberPattern.ReplaceAllStringFunc(s, func(m string) string {
+			if isCardNumber(m) {
+				return r.mask
+			}
+			return m
+		})
+	}
+	return s
+}
+
+// RedactKeyValue masks v entirely when key is sensitive, otherwise redacts v.
+func (r *Redactor) RedactKeyValue(key string, v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	if r.IsSensitiveKey(key) {
+		return r.mask
+	}
+	return r.Redact(v)
+}
+
+// RedactHeader returns a copy of h with sensitive headers masked.
+func (r *Redactor) RedactHeader(h http.Header) http.Header {
+	if r == nil || h == nil {
+		return h
+	}
+	out := make(http.Header, len(h))
+	for k, values := range h {
+		redacted := make([]string, len(values))
+		for i, v := range values {
+			if r.IsSensitiveKey(k) {
+				redacted[i] = r.mask
+			} else {
+				redacted[i] = r.RedactString(v)
+			}
+		}
+		out[k] = redacted
+	}
+	return out
+}
+
+// RedactAttributes returns a copy of attrs with sensitive keys masked and
+// string values scrubbed.
+func (r *Redactor) RedactAttributes(attrs ...attribute.KeyValue) []attribute.KeyValue {
+	if r == nil {
+		return attrs
+	}
+	out := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		switch {
+		case r.IsSensitiveKey(string(kv.Key)):
+			out[i] = kv.Key.String(r.mask)
+		case kv.Value.Type() == attribute.STRING:
+			out[i] = kv.Key.String(r.RedactString(kv.Value.AsString()))
+		case kv.Value.Type() == attribute.STRINGSLICE:
+			values := kv.Value.AsStringSlice()
+			for j := range values {
+				values[j] = r.RedactString(values[j])
+			}
+			out[i] = kv.Key.StringSlice(values)
+		default:
+			out[i] = kv
+		}
+	}
+	return out
+}
+
+// Redact returns a copy of v with sensitive data removed. Maps and structs
+// are walked recursively: sensitive keys and fields tagged `otools:"redact"`
+// are masked, and strings are scrubbed with the value patterns. Structs are
+// returned as map[string]interface{} keyed by their JSON names. Values nested
+// deeper than 10 levels are masked.
+func (r *Redactor) Redact(v interface{}) interface{} {
+	if r == nil || v == nil {
+		return v
+	}
+	return r.redact(reflect.ValueOf(v), 0)
+}
+
+var (
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+func (r *Redactor) redact(rv reflect.Value, depth int) interface{} {
+	if !rv.IsValid() {
+		return nil
+	}
+	// values nested deeper are not inspected, they are masked rather than
+	// returned as is
+	if depth > maxRedactDepth {
+		return r.mask
+	}
+
+	t := rv.Type()
+	switch {
+	case t.Implements(errorType):
+		if (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && rv.IsNil() {
+			return rv.Interface()
+		}
+		return r.RedactString(rv.Interface().(error).Error())
+	case t == timeType, t.Implements(jsonMarshalerType), t.Implements(textMarshalerType):
+		return rv.Interface()
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		// named string types like json.Number keep their type
+		return reflect.ValueOf(r.RedactString(rv.String())).Convert(t).Interface()
+	case reflect.Interface:
+		// the value in an interface is not a level of its own
+		if rv.IsNil() {
+			return nil
+		}
+		return r.redact(rv.Elem(), depth)
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return rv.Interface()
+		}
+		return r.redact(rv.Elem(), depth+1)
+	case reflect.Map:
+		if rv.IsNil() || rv.Type().Key().Kind() != reflect.String {
+			return rv.Interface()
+		}
+		out := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			if r.IsSensitiveKey(k) {
+				out[k] = r.mask
+				continue
+			}
+			out[k] = r.redact(iter.Value(), depth+1)
+		}
+		return out
+	case reflect.Slice:
+		if rv.IsNil() || t.Elem().Kind() == reflect.Uint8 {
+			return rv.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = r.redact(rv.Index(i), depth+1)
+		}
+		return out
+	case reflect.Struct:
+		return r.redactStruct(rv, depth)
+	}
+	return rv.Interface()
+}
+
+func (r *Redactor) redactStruct(rv reflect.Value, depth int) interface{} {
+	t := rv.Type()
+	out := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitEmpty, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		fv := rv.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		if hasRedactTag(field) || r.IsSensitiveKey(name) {
+			out[name] = r.mask
+			continue
+		}
+		out[name] = r.redact(fv, depth+1)
+	}
+	return out
+}
+
+func jsonFieldName(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(opts, "omitempty"), false
+}
+
+func hasRedactTag(field reflect.StructField) bool {
+	for _, opt := range strings.Split(field.Tag.Get(redactTag), ",") {
+		if opt == "redact" {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return keyReplacer.Replace(strings.ToLower(key))
+}
+
+// cardIssuers are the issuer (IIN) prefix ranges and lengths of the major
+// card networks, numbers outside of them, e.g. millisecond timestamps or
+// numeric IDs, are not card numbers even when they pass the Luhn check
+var cardIssuers = []struct {
+	low, high      int // range of the prefix made of the first digits digits
+	digits         int
+	minLen, maxLen int
+}{
+	{low: 4, high: 4, digits: 1, minLen: 13, maxLen: 13},       // Visa
+	{low: 4, high: 4, digits: 1, minLen: 16, maxLen: 16},       // Visa
+	{low: 4, high: 4, digits: 1, minLen: 19, maxLen: 19},       // Visa
+	{low: 51, high: 55, digits: 2, minLen: 16, maxLen: 16},     // Mastercard
+	{low: 2221, high: 2720, digits: 4, minLen: 16, maxLen: 16}, // Mastercard 2-series
+	{low: 34, high: 34, digits: 2, minLen: 15, maxLen: 15},     // American Express
+	{low: 37, high: 37, digits: 2, minLen: 15, maxLen: 15},     // American Express
+	{low: 6011, high: 6011, digits: 4, minLen: 16, maxLen: 19}, // Discover
+	{low: 644, high: 649, digits: 3, minLen: 16, maxLen: 19},   // Discover
+	{low: 65, high: 65, digits: 2, minLen: 16, maxLen: 19},     // Discover
+	{low: 3528, high: 3589, digits: 4, minLen: 16, maxLen: 19}, // JCB
+	{low: 300, high: 305, digits: 3, minLen: 14, maxLen: 19},   // Diners Club
+	{low: 36, high: 36, digits: 2, minLen: 14, maxLen: 19},     // Diners Club
+	{low: 38, high: 39, digits: 2, minLen: 16, maxLen: 19},     // Diners Club
+	{low: 62, high: 62, digits: 2, minLen: 16, maxLen: 19},     // UnionPay
+}
+
+// isCardNumber report whether number, digits optionally separated by spaces or
+// dashes, looks like a card number of a known issuer
+func isCardNumber(number string) bool {
+	digits := make([]byte, 0, len(number))
+	for i := 0; i < len(number); i++ {
+		if c := number[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		}
+	}
+
+	issuer := false
+	for _, iin := range cardIssuers {
+		if len(digits) < iin.minLen || len(digits) > iin.maxLen {
+			continue
+		}
+		prefix := 0
+		for _, c := range digits[:iin.digits] {
+			prefix = prefix*10 + int(c-'0')
+		}
+		if prefix >= iin.low && prefix <= iin.high {
+			issuer = true
+			break
+		}
+	}
+	return issuer && luhnValid(string(digits))
+}
+
+func luhnValid(number string) bool {
+	var sum, n int
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
@@ -1,9 +1,14 @@
 package outils
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strconv"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 const (
@@ -11,23 +16,149 @@ const (
 	maxPacketSize        = int(65000 * Byte)
 )
 
-func ToString(v interface{}) (s string) {
+var maxStringLength atomic.Int64
+
+func init() {
+	maxStringLength.Store(int64(maxPacketSize))
+}
+
+// SetMaxStringLength sets the limit used by ToString, values longer than
+// limit are truncated keeping their head and tail. limit <= 0 disables truncation.
+func SetMaxStringLength(limit int) {
+	maxStringLength.Store(int64(limit))
+}
+
+// MaxStringLength returns the limit used by ToString.
+func MaxStringLength() int {
+	return int(maxStringLength.Load())
+}
+
+// ToString formats v for span attributes and logs, truncated to MaxStringLength.
+func ToString(v interface{}) string {
+	return ToStringN(v, MaxStringLength())
+}
+
+// ToStringN formats v and truncates the result to limit bytes,
+// limit <= 0 means no limit.
+func ToStringN(v interface{}, limit int) string {
+	return Truncate(format(v), limit)
+}
+
+// Truncate shortens s to at most limit bytes, keeping the beginning and the
+// end of s around a marker with the number of bytes removed.
+func Truncate(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+
+	const markerFormat = "...[truncated %d bytes]..."
+	keep := limit - len(fmt.Sprintf(markerFormat, len(s)))
+	if keep <= 0 {
+		return validPrefix(s, limit)
+	}
+
+	head := validPrefix(s, keep*2/3)
+	tail := validSuffix(s, keep-keep*2/3)
+	return head + fmt.Sprintf(markerFormat, len(s)-len(head)-len(tail)) + tail
+}
+
+func format(v interface{}) string {
 	switch val := v.(type) {
+	case nil:
+		return "null"
 	case error:
-		if val != nil {
-			s = val.Error()
+		if isNilPointer(val) {
+			return "null"
 		}
+		return val.Error()
 	case string:
-		s = val
-	case int:
-		s = strconv.Itoa(val)
-	default:
-		b, _ := json.Marshal(val)
-		s = string(b)
+		return val
+	case []byte:
+		if utf8.Valid(val) {
+			return string(val)
+		}
+		return base64.StdEncoding.EncodeToString(val)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case *time.Time:
+		if val == nil {
+			return "null"
+		}
+		return val.Format(time.RFC3339Nano)
+	case time.Duration:
+		return val.String()
+	case fmt.Stringer:
+		if isNilPointer(val) {
+			return "null"
+		}
+		return val.String()
 	}
 
-	if len(s) >= maxPacketSize {
-		s = fmt.Sprintf("overflow, size is: %d, max: %d", len(s), maxPacketSize)
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(rv.Complex())
+	case reflect.Chan:
+		if rv.IsNil() {
+			return "null"
+		}
+		return fmt.Sprintf("%s(len=%d, cap=%d)", rv.Type(), rv.Len(), rv.Cap())
+	case reflect.Func, reflect.UnsafePointer:
+		if rv.IsNil() {
+			return "null"
+		}
+		return rv.Type().String()
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return "null"
+		}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v (json error: %s)", v, err.Error())
+	}
+	return string(b)
+}
+
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// validPrefix returns at most n bytes of s without splitting a rune.
+func validPrefix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// validSuffix returns at most n bytes from the end of s without splitting a rune.
+func validSuffix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	i := len(s) - n
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
 	}
-	return
+	return s[i:]
 }
@@ -0,0 +1,120 @@
+package outils
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+type nilError struct{}
+
+func (*nilError) Error() string { return "nil error" }
+
+type nilStringer struct{}
+
+func (*nilStringer) String() string { return "nil stringer" }
+
+type status string
+
+func TestFormat(t *testing.T) {
+	var (
+		nilPtr     *int
+		nilChan    chan int
+		nilFunc    func()
+		nilMap     map[string]int
+		nilSlice   []int
+		nilTime    *time.Time
+		nilErr     *nilError
+		nilStr     *nilStringer
+		nilIface   interface{}
+		someNumber = 7
+	)
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{name: "nil", in: nil, want: "null"},
+		{name: "nil interface", in: nilIface, want: "null"},
+		{name: "nil pointer", in: nilPtr, want: "null"},
+		{name: "nil channel", in: nilChan, want: "null"},
+		{name: "nil func", in: nilFunc, want: "null"},
+		{name: "nil map", in: nilMap, want: "null"},
+		{name: "nil slice", in: nilSlice, want: "null"},
+		{name: "nil *time.Time", in: nilTime, want: "null"},
+		{name: "nil error pointer", in: nilErr, want: "null"},
+		{name: "nil stringer pointer", in: nilStr, want: "null"},
+		{name: "channel", in: make(chan int, 3), want: "chan int(len=0, cap=3)"},
+		{name: "func", in: func() {}, want: "func()"},
+		{name: "pointer", in: &someNumber, want: "7"},
+		{name: "string", in: "plain", want: "plain"},
+		{name: "string kind", in: status("active"), want: "active"},
+		{name: "valid UTF-8 bytes", in: []byte("héllo"), want: "héllo"},
+		{name: "invalid UTF-8 bytes", in: []byte{0xff, 0xfe}, want: "//4="},
+		{name: "bool", in: true, want: "true"},
+		{name: "int8", in: int8(-3), want: "-3"},
+		{name: "max uint64", in: uint64(math.MaxUint64), want: "18446744073709551615"},
+		{name: "float32", in: float32(0.1), want: "0.1"},
+		{name: "float64", in: 1e21, want: "1e+21"},
+		{name: "complex", in: complex(1, 2), want: "(1+2i)"},
+		{name: "duration", in: 1500 * time.Millisecond, want: "1.5s"},
+		{name: "time", in: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC), want: "2024-01-02T03:04:05.000000006Z"},
+		{name: "map", in: map[string]int{"a": 1}, want: `{"a":1}`},
+		{name: "struct", in: struct {
+			Name string `json:"name"`
+		}{Name: "x"}, want: `{"name":"x"}`},
+		{name: "json error", in: struct{ F float64 }{F: math.Inf(1)}, want: "{F:+Inf} (json error: json: unsupported value: +Inf)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := format(tt.in); got != tt.want {
+				t.Fatalf("format(%#v) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	digits := strings.Repeat("0123456789", 10)
+	tests := []struct {
+		name  string
+		in    string
+		limit int
+		want  string
+	}{
+		{name: "no limit", in: digits, limit: 0, want: digits},
+		{name: "negative limit", in: digits, limit: -1, want: digits},
+		{name: "within limit", in: digits, limit: 100, want: digits},
+		{name: "head and tail", in: digits, limit: 40, want: "01234567...[truncated 87 bytes]...56789"},
+		{name: "rune-safe head and tail", in: strings.Repeat("é", 50), limit: 40, want: "éééé...[truncated 88 bytes]...éé"},
+		{name: "rune-safe tail", in: "ab" + strings.Repeat("€", 30), limit: 40, want: "ab€€...[truncated 81 bytes]...€"},
+		{name: "limit of the marker length", in: digits, limit: 27, want: digits[:27]},
+		{name: "limit shorter than the marker", in: "héllo world", limit: 5, want: "héll"},
+		{name: "limit inside the first rune", in: "héllo world", limit: 2, want: "h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.in, tt.limit)
+			if got != tt.want {
+				t.Fatalf("Truncate(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+			}
+			if tt.limit > 0 && len(got) > tt.limit {
+				t.Fatalf("got %d bytes, over the limit %d", len(got), tt.limit)
+			}
+			if !utf8.ValidString(got) {
+				t.Fatalf("got invalid UTF-8 %q", got)
+			}
+		})
+	}
+}
+
+func TestToStringN(t *testing.T) {
+	if got := ToStringN([]byte{0xff, 0xfe, 0xfd, 0xfc}, 4); got != "//79" {
+		t.Fatalf("got %q, want the base64 form truncated", got)
+	}
+	if got := ToStringN(map[string]string{"k": strings.Repeat("v", 100)}, 40); len(got) > 40 || !strings.HasPrefix(got, `{"k":"v`) {
+		t.Fatalf("got %q, want the JSON truncated to 40 bytes", got)
+	}
+}
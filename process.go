@@ -0,0 +1,164 @@
+package otools
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	otermetric "go.opentelemetry.io/otel/metric"
+)
+
+const processMeterName = "github.com/rudiarta/otools/process"
+
+// gcPauseMetric is the runtime/metrics histogram of GC stop-the-world pauses
+const gcPauseMetric = "/sched/pauses/total/gc:seconds"
+
+var gcPauseBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// processStat is read from the OS, see readProcessStat
+type processStat struct {
+	userTime   float64
+	systemTime float64
+	rss        int64
+	openFDs    int64
+}
+
+// startProcessMetrics register CPU time, RSS, open FDs, GC pauses and build
+// info on provider. CPU, RSS and FDs are only available on linux. The
+// goroutine count is only registered with goroutines, the runtime
+// instrumentation already has go.goroutine.count.
+func startProcessMetrics(provider otermetric.MeterProvider, goroutines bool) error {
+	meter := provider.Meter(processMeterName)
+
+	cpuTime, err1 := meter.Float64ObservableCounter("process.cpu.time",
+		otermetric.WithDescription("Total CPU seconds broken down by mode"),
+		otermetric.WithUnit("s"))
+	memory, err2 := meter.Int64ObservableUpDownCounter("process.memory.usage",
+		otermetric.WithDescription("The amount of physical memory in use"),
+		otermetric.WithUnit("By"))
+	fds, err3 := meter.Int64ObservableUpDownCounter("process.open_file_descriptor.count",
+		otermetric.WithDescription("Number of file descriptors in use by the process"),
+		otermetric.WithUnit("{file_descriptor}"))
+	buildInfo, err4 := meter.Int64ObservableGauge("build.info",
+		otermetric.WithDescription("Build information of the binary, the value is always 1"),
+		otermetric.WithUnit("1"))
+	gcPause, err5 := meter.Float64Histogram("go.gc.pause.duration",
+		otermetric.WithDescription("Distribution of GC stop-the-world pauses"),
+		otermetric.WithUnit("s"),
+		otermetric.WithExplicitBucketBoundaries(gcPauseBuckets...))
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return err
+	}
+
+	observables := []otermetric.Observable{cpuTime, memory, fds, buildInfo}
+	var goroutineCount otermetric.Int64ObservableUpDownCounter
+	if goroutines {
+		var err error
+		goroutineCount, err = meter.Int64ObservableUpDownCounter("go.goroutine.count",
+			otermetric.WithDescription("Count of live goroutines"),
+			otermetric.WithUnit("{goroutine}"))
+		if err != nil {
+			return err
+		}
+		observables = append(observables, goroutineCount)
+	}
+
+	pauses := &gcPauseRecorder{histogram: gcPause}
+	buildAttrs := otermetric.WithAttributes(buildInfoAttributes()...)
+	userMode := otermetric.WithAttributes(attribute.String("cpu.mode", "user"))
+	systemMode := otermetric.WithAttributes(attribute.String("cpu.mode", "system"))
+
+	_, err := meter.RegisterCallback(func(ctx context.Context, o otermetric.Observer) error {
+		if stat, ok := readProcessStat(); ok {
+			o.ObserveFloat64(cpuTime, stat.userTime, userMode)
+			o.ObserveFloat64(cpuTime, stat.systemTime, systemMode)
+			o.ObserveInt64(memory, stat.rss)
+			o.ObserveInt64(fds, stat.openFDs)
+		}
+		if goroutineCount != nil {
+			o.ObserveInt64(goroutineCount, int64(runtime.NumGoroutine()))
+		}
+		o.ObserveInt64(buildInfo, 1, buildAttrs)
+		pauses.record(ctx)
+		return nil
+	}, observables...)
+	return err
+}
+
+// buildInfoAttributes describe the binary from debug.ReadBuildInfo
+func buildInfoAttributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{attribute.String("go.version", runtime.Version())}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return attrs
+	}
+	attrs = append(attrs,
+		attribute.String("module.path", info.Main.Path),
+		attribute.String("module.version", info.Main.Version),
+	)
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified":
+			attrs = append(attrs, attribute.String(setting.Key, setting.Value))
+		}
+	}
+	return attrs
+}
+
+// gcPauseRecorder copy the pauses counted by the runtime since the previous
+// collection into a histogram, it runs in the collection callback so the
+// recordings are part of the same collection
+type gcPauseRecorder struct {
+	mu        sync.Mutex
+	histogram otermetric.Float64Histogram
+	samples   []metrics.Sample
+	last      []uint64
+}
+
+func (r *gcPauseRecorder) record(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == nil {
+		r.samples = []metrics.Sample{{Name: gcPauseMetric}}
+	}
+	metrics.Read(r.samples)
+	if r.samples[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return
+	}
+
+	h := r.samples[0].Value.Float64Histogram()
+	if len(r.last) != len(h.Counts) {
+		r.last = make([]uint64, len(h.Counts))
+	}
+	for i, count := range h.Counts {
+		delta := count - r.last[i]
+		r.last[i] = count
+		if delta == 0 {
+			continue
+		}
+
+		value := bucketValue(h.Buckets[i], h.Buckets[i+1])
+		for ; delta > 0; delta-- {
+			r.histogram.Record(ctx, value)
+		}
+	}
+}
+
+// bucketValue pick the value recorded for a runtime/metrics bucket
+func bucketValue(lower, upper float64) float64 {
+	switch {
+	case math.IsInf(lower, -1):
+		return upper
+	case math.IsInf(upper, 1):
+		return lower
+	default:
+		return (lower + upper) / 2
+	}
+}
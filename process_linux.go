@@ -0,0 +1,42 @@
+package otools
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is USER_HZ, 100 on every common linux platform
+const clockTicks = 100
+
+// readProcessStat read CPU time and RSS from /proc/self/stat and count the
+// entries of /proc/self/fd
+func readProcessStat() (processStat, bool) {
+	b, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return processStat{}, false
+	}
+
+	// the command name can contain spaces, fields start after its ")"
+	s := string(b)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(fields) < 22 {
+		return processStat{}, false
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	rss, err3 := strconv.ParseInt(fields[21], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return processStat{}, false
+	}
+
+	stat := processStat{
+		userTime:   float64(utime) / clockTicks,
+		systemTime: float64(stime) / clockTicks,
+		rss:        rss * int64(os.Getpagesize()),
+	}
+	if entries, err := os.ReadDir("/proc/self/fd"); err == nil {
+		stat.openFDs = int64(len(entries))
+	}
+	return stat, true
+}
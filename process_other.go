@@ -0,0 +1,8 @@
+//go:build !linux
+
+package otools
+
+// readProcessStat is only implemented on linux
+func readProcessStat() (processStat, bool) {
+	return processStat{}, false
+}
@@ -0,0 +1,40 @@
+package otools
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGoroutineCountRegisteredOnce(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		opts []MetricOption
+	}{
+		// the runtime instrumentation only has go.goroutine.count without its
+		// deprecated metrics
+		{name: "runtime metrics", env: "false"},
+		{name: "without runtime metrics", opts: []MetricOption{WithoutRuntimeMetrics()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("OTEL_GO_X_DEPRECATED_RUNTIME_METRICS", tt.env)
+			}
+			tel := NewTelemetry()
+			opts := append([]MetricOption{WithPrometheus(), WithoutPushExporter()}, tt.opts...)
+			if err := tel.InitMetrics("", "svc", "local", opts...); err != nil {
+				t.Fatal(err)
+			}
+			defer tel.ShutDownMeterProvider()
+
+			rec := httptest.NewRecorder()
+			tel.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			body := rec.Body.String()
+			if got := strings.Count(body, "\ngo_goroutine_count{"); got != 1 {
+				t.Fatalf("got go.goroutine.count %d times, want once:\n%s", got, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package otools
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// newPrometheusReader create a Prometheus exporter on its own registry, so
+// InitMetrics can be called again without duplicate registration
+func newPrometheusReader(opts ...otelprometheus.Option) (metric.Reader, *prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	opts = append([]otelprometheus.Option{otelprometheus.WithRegisterer(registry)}, opts...)
+	reader, err := otelprometheus.New(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reader, registry, nil
+}
+
+// MetricsHandler serve the metrics of InitMetrics with WithPrometheus for
+// Prometheus scraping, e.g. http.Handle("/metrics", otools.MetricsHandler()).
+// OpenMetrics is negotiated so histograms carry exemplars with the trace_id
+// of sampled spans, the same ID as GetTraceID. Responds 503 until metrics are
+// initialised with WithPrometheus.
+func MetricsHandler() http.Handler {
+	return defaultTelemetry.MetricsHandler()
+}
+
+// MetricsHandler see otools.MetricsHandler
+func (t *Telemetry) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.metricMu.RLock()
+		registry := t.promRegistry
+		t.metricMu.RUnlock()
+
+		if registry == nil {
+			http.Error(w, "prometheus metrics are not initialised", http.StatusServiceUnavailable)
+			return
+		}
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(w, r)
+	})
+}
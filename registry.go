@@ -0,0 +1,204 @@
+package otools
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// InstrumentKind is the type of instrument created by the metric helpers
+type InstrumentKind string
+
+const (
+	InstrumentKindFloat64Histogram             InstrumentKind = "Float64Histogram"
+	InstrumentKindInt64Histogram               InstrumentKind = "Int64Histogram"
+	InstrumentKindInt64Counter                 InstrumentKind = "Int64Counter"
+	InstrumentKindFloat64Counter               InstrumentKind = "Float64Counter"
+	InstrumentKindInt64UpDownCounter           InstrumentKind = "Int64UpDownCounter"
+	InstrumentKindFloat64Gauge                 InstrumentKind = "Float64Gauge"
+	InstrumentKindInt64Gauge                   InstrumentKind = "Int64Gauge"
+	InstrumentKindInt64ObservableCounter       InstrumentKind = "Int64ObservableCounter"
+	InstrumentKindInt64ObservableUpDownCounter InstrumentKind = "Int64ObservableUpDownCounter"
+	InstrumentKindFloat64ObservableGauge       InstrumentKind = "Float64ObservableGauge"
+)
+
+// InstrumentDescriptor describe a registered instrument
+type InstrumentDescriptor struct {
+	Name        string
+	Kind        InstrumentKind
+	Description string
+	Unit        string
+	// Buckets are the explicit bucket boundaries of a histogram, nil for the
+	// default boundaries
+	Buckets []float64
+}
+
+// ErrInstrumentConflict is wrapped by the errors of InstrumentConflicts
+var ErrInstrumentConflict = errors.New("conflicting instrument definition")
+
+// instrumentKey identify an instrument, names are case-insensitive in OTel
+type instrumentKey struct {
+	name string
+	kind InstrumentKind
+}
+
+type registeredInstrument struct {
+	desc       InstrumentDescriptor
+	instrument delegatingInstrument
+}
+
+// registerInstrument return the cached instrument for desc, or create and
+// register a new one. Observables are not cached since every call carries
+// its own callback, their definition is still checked for conflicts.
+func (t *Telemetry) registerInstrument(desc InstrumentDescriptor, cache bool, create func() delegatingInstrument) delegatingInstrument {
+	// the instrument is delegated and errors are logged once metricMu is
+	// released, the SDK collects under its own lock and calls back into
+	// callbacks that may create instruments, logging may export and
+	// exporting records metrics
+	instrument, update, errs := t.lookupOrCreateInstrument(desc, cache, create)
+	errs = append(errs, update.apply()...)
+	t.logErrors(errs)
+	return instrument
+}
+
+// lookupOrCreateInstrument is the part of registerInstrument holding
+// metricMu, update is what still has to be delegated
+func (t *Telemetry) lookupOrCreateInstrument(desc InstrumentDescriptor, cache bool, create func() delegatingInstrument) (delegatingInstrument, meterUpdate, []error) {
+	var errs []error
+	t.metricMu.Lock()
+	defer t.metricMu.Unlock()
+
+	if t.registry == nil {
+		t.registry = map[instrumentKey]*registeredInstrument{}
+	}
+
+	name := strings.ToLower(desc.Name)
+	key := instrumentKey{name: name, kind: desc.Kind}
+	if existing, ok := t.registry[key]; ok {
+		if existing.desc.Description != desc.Description || existing.desc.Unit != desc.Unit {
+			errs = t.addConflict(errs, desc, "definition", fmt.Errorf("%w: %s %q registered with description %q unit %q, got description %q unit %q",
+				ErrInstrumentConflict, desc.Kind, desc.Name,
+				existing.desc.Description, existing.desc.Unit, desc.Description, desc.Unit))
+		}
+		if len(desc.Buckets) > 0 && !slices.Equal(existing.desc.Buckets, desc.Buckets) {
+			errs = t.addConflict(errs, desc, "buckets", fmt.Errorf("%w: %s %q registered with buckets %v, got buckets %v",
+				ErrInstrumentConflict, desc.Kind, desc.Name, existing.desc.Buckets, desc.Buckets))
+		}
+		if cache {
+			return existing.instrument, meterUpdate{}, errs
+		}
+	} else {
+		for k, other := range t.registry {
+			if k.name == name {
+				errs = t.addConflict(errs, desc, "kind", fmt.Errorf("%w: %q registered as %s, got %s",
+					ErrInstrumentConflict, desc.Name, other.desc.Kind, desc.Kind))
+				break
+			}
+		}
+	}
+
+	instrument := create()
+	instrument.limiter().setup(desc, t.Wf)
+	instrument.limiter().setLimit(t.cardinality)
+	if _, ok := t.registry[key]; !ok {
+		t.registry[key] = &registeredInstrument{desc: desc, instrument: instrument}
+	}
+	t.instruments = append(t.instruments, instrument)
+	if o, ok := instrument.(*observable); ok {
+		o.remove = func() { t.removeInstrument(o) }
+	}
+	var update meterUpdate
+	if t.isInitMetric && t.meter != nil {
+		update = meterUpdate{meter: t.meter, gen: t.meterGen, instruments: []delegatingInstrument{instrument}}
+	}
+	return instrument, update, errs
+}
+
+// removeInstrument stop delegating instrument, e.g. an unregistered observable
+func (t *Telemetry) removeInstrument(instrument delegatingInstrument) {
+	t.metricMu.Lock()
+	defer t.metricMu.Unlock()
+
+	t.instruments = slices.DeleteFunc(t.instruments, func(i delegatingInstrument) bool {
+		return i == instrument
+	})
+}
+
+// lookupInstrument return the registered instrument named name of kind, nil
+// when there is none
+func (t *Telemetry) lookupInstrument(name string, kind InstrumentKind) delegatingInstrument {
+	t.metricMu.RLock()
+	defer t.metricMu.RUnlock()
+
+	if registered, ok := t.registry[instrumentKey{name: strings.ToLower(name), kind: kind}]; ok {
+		return registered.instrument
+	}
+	return nil
+}
+
+// addConflict keep err for InstrumentConflicts and append it to errs to be
+// logged, a conflicting definition is kept and logged once however often the
+// helper is called, metricMu must be held
+func (t *Telemetry) addConflict(errs []error, desc InstrumentDescriptor, reason string, err error) []error {
+	key := conflictKey{reason: reason, name: strings.ToLower(desc.Name), kind: desc.Kind, description: desc.Description, unit: desc.Unit, buckets: fmt.Sprint(desc.Buckets)}
+	if _, ok := t.conflictKeys[key]; ok {
+		return errs
+	}
+	if t.conflictKeys == nil {
+		t.conflictKeys = map[conflictKey]struct{}{}
+	}
+	t.conflictKeys[key] = struct{}{}
+	t.conflicts = append(t.conflicts, err)
+	return append(errs, err)
+}
+
+// conflictKey identify a conflicting definition
+type conflictKey struct {
+	reason      string
+	name        string
+	kind        InstrumentKind
+	description string
+	unit        string
+	buckets     string
+}
+
+// RegisteredInstruments list every instrument created by the metric helpers
+// sorted by name, e.g. to generate metric documentation
+func RegisteredInstruments() []InstrumentDescriptor {
+	return defaultTelemetry.RegisteredInstruments()
+}
+
+// RegisteredInstruments see otools.RegisteredInstruments
+func (t *Telemetry) RegisteredInstruments() []InstrumentDescriptor {
+	t.metricMu.RLock()
+	defer t.metricMu.RUnlock()
+
+	descs := make([]InstrumentDescriptor, 0, len(t.registry))
+	for _, registered := range t.registry {
+		descs = append(descs, registered.desc)
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		if descs[i].Name != descs[j].Name {
+			return descs[i].Name < descs[j].Name
+		}
+		return descs[i].Kind < descs[j].Kind
+	})
+	return descs
+}
+
+// InstrumentConflicts get the conflicting definitions found so far, same name
+// with another kind, description, unit or histogram buckets. Every error wraps
+// ErrInstrumentConflict
+func InstrumentConflicts() []error {
+	return defaultTelemetry.InstrumentConflicts()
+}
+
+// InstrumentConflicts see otools.InstrumentConflicts
+func (t *Telemetry) InstrumentConflicts() []error {
+	t.metricMu.RLock()
+	defer t.metricMu.RUnlock()
+
+	return append([]error(nil), t.conflicts...)
+}
@@ -0,0 +1,78 @@
+package otools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	otermetric "go.opentelemetry.io/otel/metric"
+)
+
+func TestRegisterInstrumentConflictsOnce(t *testing.T) {
+	tel := NewTelemetry()
+
+	first := tel.CounterMetric("orders", "orders placed", "1")
+	for i := 0; i < 100; i++ {
+		if got := tel.CounterMetric("orders", "orders created", "1"); got != first {
+			t.Fatalf("call %d: got a new instrument, want the cached one", i)
+		}
+	}
+	tel.HistogramMetric("orders", "orders placed", "ms")
+	tel.HistogramMetric("orders", "orders placed", "ms")
+
+	conflicts := tel.InstrumentConflicts()
+	if len(conflicts) != 2 {
+		t.Fatalf("got %d conflicts, want 2: %v", len(conflicts), conflicts)
+	}
+	for _, err := range conflicts {
+		if !errors.Is(err, ErrInstrumentConflict) {
+			t.Errorf("%v does not wrap ErrInstrumentConflict", err)
+		}
+	}
+}
+
+func TestRegisterInstrumentBucketConflict(t *testing.T) {
+	tel := NewTelemetry()
+
+	first := tel.HistogramMetric("latency", "request latency", "ms", 10, 100)
+	tel.HistogramMetric("latency", "request latency", "ms")
+	tel.HistogramMetric("latency", "request latency", "ms", 10, 100)
+	if conflicts := tel.InstrumentConflicts(); len(conflicts) != 0 {
+		t.Fatalf("got conflicts %v, want none for the same or no buckets", conflicts)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := tel.HistogramMetric("latency", "request latency", "ms", 1, 5); got != first {
+			t.Fatal("got a new histogram, want the cached one")
+		}
+	}
+	conflicts := tel.InstrumentConflicts()
+	if len(conflicts) != 1 || !errors.Is(conflicts[0], ErrInstrumentConflict) {
+		t.Fatalf("got conflicts %v, want one bucket conflict", conflicts)
+	}
+}
+
+func TestUnregisterRemovesObservable(t *testing.T) {
+	tel := NewTelemetry()
+	callback := func(ctx context.Context, o otermetric.Int64Observer) error { return nil }
+
+	for i := 0; i < 10; i++ {
+		reg := tel.ObservableCounterMetric("queue_items", "items in the queue", "1", callback)
+		if err := reg.Unregister(); err != nil {
+			t.Fatal(err)
+		}
+		// unregistering twice is a no-op
+		if err := reg.Unregister(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kept := tel.ObservableCounterMetric("queue_items", "items in the queue", "1", callback)
+	defer kept.Unregister()
+
+	tel.metricMu.RLock()
+	n := len(tel.instruments)
+	tel.metricMu.RUnlock()
+	if n != 1 {
+		t.Fatalf("got %d instruments, want only the registered observable", n)
+	}
+}
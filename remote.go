@@ -0,0 +1,122 @@
+package otools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rudiarta/otools/otrace"
+	"github.com/rudiarta/otools/outils"
+	"go.opentelemetry.io/otel/attribute"
+	otermetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InvalidParentFallback decides how a span is started when the incoming
+// trace context is malformed.
+type InvalidParentFallback int32
+
+const (
+	// InvalidParentNewRoot start a new trace, default
+	InvalidParentNewRoot InvalidParentFallback = iota
+	// InvalidParentContext start a child of the span already in ctx, if any
+	InvalidParentContext
+)
+
+const (
+	rejectedTraceIDKey     = attribute.Key("otools.rejected_parent.trace_id")
+	rejectedSpanIDKey      = attribute.Key("otools.rejected_parent.span_id")
+	rejectedTraceparentKey = attribute.Key("otools.rejected_parent.traceparent")
+	rejectedReasonKey      = attribute.Key("otools.rejected_parent.reason")
+
+	// maxRejectedIDLength bound the rejected values kept on the span,
+	// they come from clients and can be anything
+	maxRejectedIDLength = 128
+)
+
+// SetInvalidParentFallback set how the package functions start spans for
+// malformed incoming IDs, see WithInvalidParentFallback
+func SetInvalidParentFallback(fallback InvalidParentFallback) {
+	defaultTelemetry.SetInvalidParentFallback(fallback)
+}
+
+// SetInvalidParentFallback see otools.SetInvalidParentFallback
+func (t *Telemetry) SetInvalidParentFallback(fallback InvalidParentFallback) {
+	t.invalidParentFallback.Store(int32(fallback))
+}
+
+// rejectedParentCounter count incoming trace contexts that were rejected
+func (t *Telemetry) rejectedParentCounter() otermetric.Int64Counter {
+	t.rejectedParentOnce.Do(func() {
+		t.rejectedParent = t.CounterMetric("otools.trace.rejected_parent",
+			"incoming trace contexts rejected because they are malformed", "1")
+	})
+	return t.rejectedParent
+}
+
+// StartTracerWithRemoteParent start a server span as child of the remote span
+// identified by traceID & spanID. When the IDs are malformed the error is
+// returned together with a usable Tracer started according to
+// SetInvalidParentFallback, with the rejected IDs recorded as attributes.
+func StartTracerWithRemoteParent(ctx context.Context, operationName, traceID, spanID string, opts ...StartOption) (Tracer, error) {
+	return defaultTelemetry.StartTracerWithRemoteParent(ctx, operationName, traceID, spanID, opts...)
+}
+
+// StartTracerWithRemoteParent see otools.StartTracerWithRemoteParent
+func (t *Telemetry) StartTracerWithRemoteParent(ctx context.Context, operationName, traceID, spanID string, opts ...StartOption) (Tracer, error) {
+	spanContext, err := otrace.NewSpanContext(otrace.NewRequest{
+		TraceID: traceID,
+		SpanID:  spanID,
+	})
+
+	return t.startRemoteTrace(ctx, operationName, spanContext, err, []attribute.KeyValue{
+		rejectedTraceIDKey.String(outils.Truncate(traceID, maxRejectedIDLength)),
+		rejectedSpanIDKey.String(outils.Truncate(spanID, maxRejectedIDLength)),
+	}, opts...)
+}
+
+// StartTracerWithTraceparent start a server span as child of the remote span
+// in a W3C traceparent header, malformed values are handled like
+// StartTracerWithRemoteParent
+func StartTracerWithTraceparent(ctx context.Context, operationName, traceparent string, opts ...StartOption) (Tracer, error) {
+	return defaultTelemetry.StartTracerWithTraceparent(ctx, operationName, traceparent, opts...)
+}
+
+// StartTracerWithTraceparent see otools.StartTracerWithTraceparent
+func (t *Telemetry) StartTracerWithTraceparent(ctx context.Context, operationName, traceparent string, opts ...StartOption) (Tracer, error) {
+	spanContext, err := otrace.ParseTraceparent(traceparent)
+
+	return t.startRemoteTrace(ctx, operationName, spanContext, err, []attribute.KeyValue{
+		rejectedTraceparentKey.String(outils.Truncate(traceparent, maxRejectedIDLength)),
+	}, opts...)
+}
+
+func (t *Telemetry) startRemoteTrace(ctx context.Context, operationName string, spanContext trace.SpanContext, err error, rejected []attribute.KeyValue, opts ...StartOption) (Tracer, error) {
+	opts = append([]StartOption{WithSpanKind(trace.SpanKindServer)}, opts...)
+
+	if err == nil {
+		requestContext := trace.ContextWithRemoteSpanContext(ctx, spanContext)
+		return t.startTrace(requestContext, t.getTracer(ctx), operationName, opts...), nil
+	}
+
+	reason := rejectReason(err)
+	t.Wf(ctx, "rejected incoming trace context for %s: %s", operationName, err.Error())
+	t.rejectedParentCounter().Add(ctx, 1, otermetric.WithAttributes(rejectedReasonKey.String(reason)))
+
+	opts = append(opts, WithAttributes(append(rejected, rejectedReasonKey.String(reason))...))
+	if InvalidParentFallback(t.invalidParentFallback.Load()) == InvalidParentNewRoot {
+		opts = append(opts, WithNewRoot())
+	}
+	return t.startTrace(ctx, t.getTracer(ctx), operationName, opts...), err
+}
+
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, otrace.ErrInvalidTraceID):
+		return "invalid_trace_id"
+	case errors.Is(err, otrace.ErrInvalidSpanID):
+		return "invalid_span_id"
+	case errors.Is(err, otrace.ErrInvalidTraceparent):
+		return "invalid_traceparent"
+	}
+	return "unknown"
+}
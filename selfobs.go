@@ -0,0 +1,346 @@
+package otools
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/rudiarta/otools/otrace"
+	"go.opentelemetry.io/otel/attribute"
+	otermetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// ExporterExportedMetric counts spans, metric data points and log records
+	// accepted by the exporter
+	ExporterExportedMetric = "otools.exporter.exported"
+	// ExporterFailedMetric counts items of failed exports
+	ExporterFailedMetric = "otools.exporter.failed"
+	// ExporterBufferedMetric counts items of exports buffered by the collector
+	// connection while the collector is unreachable, see otrace.Conn
+	ExporterBufferedMetric = "otools.exporter.buffered"
+	// ExporterDroppedMetric counts spans and log records dropped because the
+	// batch processor queue was full
+	ExporterDroppedMetric = "otools.exporter.dropped"
+	// ExporterQueueSizeMetric is the number of items waiting in the batch
+	// processor
+	ExporterQueueSizeMetric = "otools.exporter.queue.size"
+	// ExporterDurationMetric is the histogram of export durations in ms
+	ExporterDurationMetric = "otools.exporter.duration"
+)
+
+const (
+	signalTrace  = "trace"
+	signalMetric = "metric"
+	signalLog    = "log"
+
+	// defaultQueueSize is the queue size of the span and log batch processors
+	defaultQueueSize = tracesdk.DefaultMaxQueueSize
+)
+
+const exporterMeterName = "github.com/rudiarta/otools/exporter"
+
+// exportStats count the exports of one signal, the counters live as long as
+// the Telemetry so they keep growing across re-initialisation
+type exportStats struct {
+	exported atomic.Uint64
+	failed   atomic.Uint64
+	buffered atomic.Uint64
+	dropped  atomic.Uint64
+	// queue is the queue of the current provider, nil when there is none
+	queue      atomic.Pointer[exportQueue]
+	lastExport atomic.Int64
+	lastError  atomic.Pointer[errorRecord]
+}
+
+type errorRecord struct {
+	message string
+	time    time.Time
+}
+
+// exportQueue count the items of one provider handed to its batch processor
+// and not exported yet
+type exportQueue struct {
+	size atomic.Int64
+	max  int64
+}
+
+// reserve take room for n items, false when the queue is full
+func (q *exportQueue) reserve(n int64) bool {
+	for {
+		size := q.size.Load()
+		if size+n > q.max {
+			return false
+		}
+		if q.size.CompareAndSwap(size, size+n) {
+			return true
+		}
+	}
+}
+
+func (q *exportQueue) release(n int64) {
+	if q != nil {
+		q.size.Add(-n)
+	}
+}
+
+// export run fn with a context telling whether the collector connection
+// buffered the request and record the export of n items to s and duration
+func export(ctx context.Context, s *exportStats, duration otermetric.Float64Histogram, signal string, n int, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	ctx, result := otrace.WithExportResult(ctx, n)
+	err := fn(ctx)
+	d := time.Since(start)
+
+	outcome := "success"
+	switch {
+	case err != nil:
+		outcome = "error"
+		s.failed.Add(uint64(n))
+		s.lastError.Store(&errorRecord{message: err.Error(), time: time.Now()})
+	case result.Buffered():
+		outcome = "buffered"
+		s.buffered.Add(uint64(n))
+	default:
+		s.exported.Add(uint64(n))
+		s.lastExport.Store(time.Now().UnixNano())
+	}
+	duration.Record(ctx, durationIn("ms", d), otermetric.WithAttributes(
+		attribute.String("signal", signal),
+		OutcomeAttributeKey.String(outcome),
+	))
+	return err
+}
+
+// exportDurationHistogram is resolved once when an exporter is wrapped, never
+// while exporting, the registry lock must not be taken on the export path
+// since registering logs and logging may export synchronously
+func (t *Telemetry) exportDurationHistogram() otermetric.Float64Histogram {
+	t.exportDurationOnce.Do(func() {
+		t.exportDuration = t.HistogramMetric(ExporterDurationMetric, "Duration of exports by signal and outcome", "ms")
+	})
+	return t.exportDuration
+}
+
+// countingSpanProcessor bound the spans handed to the batch processor so
+// spans dropped because its queue is full are counted
+type countingSpanProcessor struct {
+	tracesdk.SpanProcessor
+	stats *exportStats
+	queue *exportQueue
+}
+
+// newSpanProcessor create the processor exporting to exp, spans are counted
+// in traceStats
+func (t *Telemetry) newSpanProcessor(exp tracesdk.SpanExporter, cfg traceConfig) tracesdk.SpanProcessor {
+	if cfg.simple {
+		t.traceStats.queue.Store(nil)
+		return tracesdk.NewSimpleSpanProcessor(t.newCountingSpanExporter(exp, nil))
+	}
+
+	queue := &exportQueue{max: int64(cfg.batch.queueSize())}
+	t.traceStats.queue.Store(queue)
+	return &countingSpanProcessor{
+		SpanProcessor: tracesdk.NewBatchSpanProcessor(t.newCountingSpanExporter(exp, queue),
+			cfg.batch.spanOptions()...),
+		stats: &t.traceStats,
+		queue: queue,
+	}
+}
+
+func (p *countingSpanProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	// the batch processor ignores spans that are not sampled
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	if !p.queue.reserve(1) {
+		p.stats.dropped.Add(1)
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+func (p *countingSpanProcessor) Shutdown(ctx context.Context) error {
+	err := p.SpanProcessor.Shutdown(ctx)
+	p.stats.queue.CompareAndSwap(p.queue, nil)
+	return err
+}
+
+type countingSpanExporter struct {
+	tracesdk.SpanExporter
+	stats    *exportStats
+	duration otermetric.Float64Histogram
+	queue    *exportQueue
+}
+
+func (t *Telemetry) newCountingSpanExporter(exp tracesdk.SpanExporter, queue *exportQueue) *countingSpanExporter {
+	return &countingSpanExporter{SpanExporter: exp, stats: &t.traceStats, duration: t.exportDurationHistogram(), queue: queue}
+}
+
+func (e *countingSpanExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	return export(ctx, e.stats, e.duration, signalTrace, len(spans), func(ctx context.Context) error {
+		defer e.queue.release(int64(len(spans)))
+		return e.SpanExporter.ExportSpans(ctx, spans)
+	})
+}
+
+// countingLogProcessor bound the records handed to the batch processor, which
+// would otherwise drop the oldest records silently when its queue is full
+type countingLogProcessor struct {
+	log.Processor
+	stats *exportStats
+	queue *exportQueue
+}
+
+// newLogProcessor create the processor exporting to exp, records are counted
+// in logStats
+func (t *Telemetry) newLogProcessor(exp log.Exporter, cfg logConfig) log.Processor {
+	if cfg.simple {
+		t.logStats.queue.Store(nil)
+		return log.NewSimpleProcessor(t.newCountingLogExporter(exp, nil))
+	}
+
+	queue := &exportQueue{max: int64(cfg.batch.queueSize())}
+	t.logStats.queue.Store(queue)
+	return &countingLogProcessor{
+		Processor: log.NewBatchProcessor(t.newCountingLogExporter(exp, queue),
+			cfg.batch.logOptions()...),
+		stats: &t.logStats,
+		queue: queue,
+	}
+}
+
+func (p *countingLogProcessor) OnEmit(ctx context.Context, record *log.Record) error {
+	if !p.queue.reserve(1) {
+		p.stats.dropped.Add(1)
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, record)
+}
+
+func (p *countingLogProcessor) Shutdown(ctx context.Context) error {
+	err := p.Processor.Shutdown(ctx)
+	p.stats.queue.CompareAndSwap(p.queue, nil)
+	return err
+}
+
+type countingLogExporter struct {
+	log.Exporter
+	stats    *exportStats
+	duration otermetric.Float64Histogram
+	queue    *exportQueue
+}
+
+func (t *Telemetry) newCountingLogExporter(exp log.Exporter, queue *exportQueue) *countingLogExporter {
+	return &countingLogExporter{Exporter: exp, stats: &t.logStats, duration: t.exportDurationHistogram(), queue: queue}
+}
+
+func (e *countingLogExporter) Export(ctx context.Context, records []log.Record) error {
+	return export(ctx, e.stats, e.duration, signalLog, len(records), func(ctx context.Context) error {
+		defer e.queue.release(int64(len(records)))
+		return e.Exporter.Export(ctx, records)
+	})
+}
+
+// countingMetricExporter count exported data points, the periodic reader has
+// no queue so the data points of a failed export are lost
+type countingMetricExporter struct {
+	metric.Exporter
+	stats    *exportStats
+	duration otermetric.Float64Histogram
+}
+
+func (t *Telemetry) newCountingMetricExporter(exp metric.Exporter) *countingMetricExporter {
+	return &countingMetricExporter{Exporter: exp, stats: &t.metricStats, duration: t.exportDurationHistogram()}
+}
+
+func (e *countingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return export(ctx, e.stats, e.duration, signalMetric, dataPointCount(rm), func(ctx context.Context) error {
+		return e.Exporter.Export(ctx, rm)
+	})
+}
+
+func dataPointCount(rm *metricdata.ResourceMetrics) int {
+	n := 0
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Summary:
+				n += len(data.DataPoints)
+			}
+		}
+	}
+	return n
+}
+
+// startExporterMetrics register the export counts and queue sizes of every
+// signal on provider
+func (t *Telemetry) startExporterMetrics(provider otermetric.MeterProvider) error {
+	meter := provider.Meter(exporterMeterName)
+
+	exported, err1 := meter.Int64ObservableCounter(ExporterExportedMetric,
+		otermetric.WithDescription("Items accepted by the exporter by signal"),
+		otermetric.WithUnit("{item}"))
+	failed, err2 := meter.Int64ObservableCounter(ExporterFailedMetric,
+		otermetric.WithDescription("Items of failed exports by signal"),
+		otermetric.WithUnit("{item}"))
+	buffered, err5 := meter.Int64ObservableCounter(ExporterBufferedMetric,
+		otermetric.WithDescription("Items of exports buffered while the collector is unreachable by signal"),
+		otermetric.WithUnit("{item}"))
+	dropped, err3 := meter.Int64ObservableCounter(ExporterDroppedMetric,
+		otermetric.WithDescription("Items dropped because the batch processor queue was full"),
+		otermetric.WithUnit("{item}"))
+	queueSize, err4 := meter.Int64ObservableUpDownCounter(ExporterQueueSizeMetric,
+		otermetric.WithDescription("Items waiting in the batch processor by signal"),
+		otermetric.WithUnit("{item}"))
+	if err := errors.Join(err1, err2, err3, err4, err5); err != nil {
+		return err
+	}
+
+	_, err := meter.RegisterCallback(func(_ context.Context, o otermetric.Observer) error {
+		for signal, s := range t.allExportStats() {
+			attrs := otermetric.WithAttributes(attribute.String("signal", signal))
+			o.ObserveInt64(exported, int64(s.exported.Load()), attrs)
+			o.ObserveInt64(failed, int64(s.failed.Load()), attrs)
+			o.ObserveInt64(buffered, int64(s.buffered.Load()), attrs)
+			if signal == signalMetric {
+				continue
+			}
+			o.ObserveInt64(dropped, int64(s.dropped.Load()), attrs)
+			if q := s.queue.Load(); q != nil {
+				o.ObserveInt64(queueSize, q.size.Load(), attrs)
+			}
+		}
+		return nil
+	}, exported, failed, buffered, dropped, queueSize)
+	return err
+}
+
+func (t *Telemetry) allExportStats() map[string]*exportStats {
+	return map[string]*exportStats{
+		signalTrace:  &t.traceStats,
+		signalMetric: &t.metricStats,
+		signalLog:    &t.logStats,
+	}
+}
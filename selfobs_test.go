@@ -0,0 +1,39 @@
+package otools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rudiarta/otools/otrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestBufferedExportNotCountedAsExported(t *testing.T) {
+	ctx := context.Background()
+	// nothing listens on port 1, exports fail with Unavailable and are buffered
+	conn, err := otrace.Dial(ctx, "127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	exp, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn.ClientConn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tel := NewTelemetry()
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}}.Snapshots()
+	if err := tel.newCountingSpanExporter(exp, nil).ExportSpans(ctx, spans); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &tel.traceStats
+	if s.exported.Load() != 0 || s.failed.Load() != 0 || s.buffered.Load() != 2 {
+		t.Fatalf("got exported %d, failed %d, buffered %d, want 2 buffered",
+			s.exported.Load(), s.failed.Load(), s.buffered.Load())
+	}
+	if conn.Pending() != 2 {
+		t.Fatalf("got %d pending items, want 2", conn.Pending())
+	}
+}
@@ -0,0 +1,72 @@
+package otools
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otermetric "go.opentelemetry.io/otel/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// SpanDurationMetric is the histogram of span durations in ms
+	SpanDurationMetric = "otools.span.duration"
+	// SpanErrorMetric counts spans ended with status error
+	SpanErrorMetric = "otools.span.errors"
+)
+
+// spanMetricsProcessor record span duration and errors when a span ends, the
+// instruments come from the metric helpers so they start recording once
+// InitMetrics is called
+type spanMetricsProcessor struct {
+	duration otermetric.Float64Histogram
+	errors   otermetric.Int64Counter
+	keys     map[attribute.Key]struct{}
+}
+
+func (t *Telemetry) newSpanMetricsProcessor(keys []string) *spanMetricsProcessor {
+	p := &spanMetricsProcessor{
+		duration: t.HistogramMetric(SpanDurationMetric, "Duration of spans by name, kind and status", "ms"),
+		errors:   t.CounterMetric(SpanErrorMetric, "Spans ended with status error by name and kind", "1"),
+		keys:     make(map[attribute.Key]struct{}, len(keys)),
+	}
+	for _, k := range keys {
+		p.keys[attribute.Key(k)] = struct{}{}
+	}
+	return p
+}
+
+func (p *spanMetricsProcessor) OnStart(context.Context, tracesdk.ReadWriteSpan) {}
+
+func (p *spanMetricsProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	attrs := []attribute.KeyValue{
+		attribute.String("span.name", s.Name()),
+		attribute.String("span.kind", s.SpanKind().String()),
+		attribute.String("status.code", s.Status().Code.String()),
+	}
+	if len(p.keys) > 0 {
+		for _, kv := range s.Attributes() {
+			if _, ok := p.keys[kv.Key]; ok {
+				attrs = append(attrs, kv)
+			}
+		}
+	}
+
+	// the span context makes the recording an exemplar of this span
+	ctx := trace.ContextWithSpanContext(context.Background(), s.SpanContext())
+	set := otermetric.WithAttributeSet(attribute.NewSet(attrs...))
+	p.duration.Record(ctx, durationIn("ms", s.EndTime().Sub(s.StartTime())), set)
+	if s.Status().Code == codes.Error {
+		p.errors.Add(ctx, 1, set)
+	}
+}
+
+func (p *spanMetricsProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,97 @@
+package otools
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StackTraceMode decides when a Tracer attaches a goroutine stack to its span.
+type StackTraceMode int32
+
+const (
+	// StackTraceOnError attaches a stack to the exception event recorded by
+	// SetError or by a panic passing through Finish. This is the default.
+	StackTraceOnError StackTraceMode = iota
+	// StackTraceOff never captures stacks, unless RecordStackTrace is called.
+	StackTraceOff
+	// StackTraceSampled behaves like StackTraceOnError and also attaches a
+	// stack event to every sampled span on Finish.
+	StackTraceSampled
+	// StackTraceAlways attaches a stack event to every span on Finish.
+	StackTraceAlways
+)
+
+// stackTraceEventName is used for stacks that are not caused by an error,
+// so backends do not show those spans as exceptions.
+const stackTraceEventName = "stacktrace"
+
+// SetStackTraceMode set when stacks are captured by the package functions,
+// see StackTraceMode and WithStackTraceMode
+func SetStackTraceMode(mode StackTraceMode) {
+	defaultTelemetry.SetStackTraceMode(mode)
+}
+
+// SetStackTraceMode see otools.SetStackTraceMode
+func (t *Telemetry) SetStackTraceMode(mode StackTraceMode) {
+	t.stackTraceMode.Store(int32(mode))
+}
+
+// GetStackTraceMode get current StackTraceMode of the package functions
+func GetStackTraceMode() StackTraceMode {
+	return defaultTelemetry.GetStackTraceMode()
+}
+
+// GetStackTraceMode see otools.GetStackTraceMode
+func (t *Telemetry) GetStackTraceMode() StackTraceMode {
+	return StackTraceMode(t.stackTraceMode.Load())
+}
+
+// recordStackTrace add the current stack as a span event
+func recordStackTrace(span trace.Span) {
+	span.AddEvent(stackTraceEventName, trace.WithAttributes(
+		semconv.ExceptionStacktraceKey.String(string(debug.Stack())),
+	))
+}
+
+// recordPanic record recovered panic value as exception event with stack
+func (t *Telemetry) recordPanic(span trace.Span, recovered interface{}) {
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("%v", recovered)
+	}
+
+	t.recordException(span, fmt.Sprintf("%T", recovered), err, semconv.ExceptionEscaped(true))
+}
+
+// recordException add an exception event and set the span status to error,
+// the message is redacted and a stack is attached unless StackTraceOff
+func (t *Telemetry) recordException(span trace.Span, typ string, err error, attrs ...attribute.KeyValue) {
+	msg := t.getRedactor().RedactString(err.Error())
+	attrs = append(attrs,
+		semconv.ExceptionTypeKey.String(typ),
+		semconv.ExceptionMessageKey.String(msg),
+	)
+	if t.GetStackTraceMode() != StackTraceOff {
+		attrs = append(attrs, semconv.ExceptionStacktraceKey.String(string(debug.Stack())))
+	}
+
+	span.AddEvent(semconv.ExceptionEventName, trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, msg)
+}
+
+// finishStackTrace add a stack event on Finish depending on StackTraceMode
+func (t *Telemetry) finishStackTrace(span trace.Span) {
+	switch t.GetStackTraceMode() {
+	case StackTraceAlways:
+		recordStackTrace(span)
+	case StackTraceSampled:
+		if span.SpanContext().IsSampled() {
+			recordStackTrace(span)
+		}
+	}
+}
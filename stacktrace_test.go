@@ -0,0 +1,96 @@
+package otools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// BenchmarkFinish compare the stack captured on every Finish, the behaviour
+// before StackTraceMode, with the StackTraceOnError default
+func BenchmarkFinish(b *testing.B) {
+	provider := tracesdk.NewTracerProvider(tracesdk.WithSampler(tracesdk.AlwaysSample()))
+	defer provider.Shutdown(context.Background())
+	tracer := provider.Tracer(toolName)
+
+	tel := NewTelemetry()
+	for _, bm := range []struct {
+		name string
+		mode StackTraceMode
+	}{
+		{name: "StackTraceAlways", mode: StackTraceAlways},
+		{name: "StackTraceOnError", mode: StackTraceOnError},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			tel.SetStackTraceMode(bm.mode)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tel.startTrace(context.Background(), tracer, "operation").Finish()
+			}
+		})
+	}
+}
+
+func TestTraceOptionsPerTelemetry(t *testing.T) {
+	chdirTemp(t)
+	newTelemetry := func(opts ...TraceOption) (*Telemetry, *tracetest.SpanRecorder) {
+		recorder := tracetest.NewSpanRecorder()
+		tel := NewTelemetry()
+		opts = append(opts, WithSpanProcessors(recorder))
+		if err := tel.InitTracer("", "svc", "local", opts...); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { tel.ShutDownTraceProvider() })
+		return tel, recorder
+	}
+
+	quiet, quietSpans := newTelemetry(WithStackTraceMode(StackTraceOff),
+		WithInvalidParentFallback(InvalidParentContext), WithRedactor(nil))
+	loud, loudSpans := newTelemetry(WithStackTraceMode(StackTraceAlways))
+	if GetStackTraceMode() != StackTraceOnError {
+		t.Fatalf("got default mode %v, want the options to leave it unchanged", GetStackTraceMode())
+	}
+
+	ctx := context.Background()
+	parent := quiet.StartTrace(ctx, "parent")
+	tr, _ := quiet.StartTracerWithRemoteParent(parent.Context(), "remote", "bad", "bad")
+	tr.SetTag("password", "hunter2")
+	tr.SetError(errors.New("login jane@example.com failed"))
+	tr.Finish()
+	parent.Finish()
+	loud.StartTrace(ctx, "loud").Finish()
+
+	spans := quietSpans.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	remote := spans[0]
+	if remote.Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Fatal("want the span in ctx as parent with InvalidParentContext")
+	}
+	got := map[attribute.Key]string{}
+	for _, kv := range remote.Attributes() {
+		got[kv.Key] = kv.Value.Emit()
+	}
+	for _, event := range remote.Events() {
+		for _, kv := range event.Attributes {
+			got[kv.Key] = kv.Value.Emit()
+		}
+	}
+	if got["password"] != "hunter2" || got[semconv.ExceptionMessageKey] != "login jane@example.com failed" {
+		t.Fatalf("got %v, want tag and message kept without redactor", got)
+	}
+	if _, ok := got[semconv.ExceptionStacktraceKey]; ok {
+		t.Fatal("got a stack with StackTraceOff")
+	}
+
+	loudEnded := loudSpans.Ended()
+	if len(loudEnded) != 1 || len(loudEnded[0].Events()) != 1 || loudEnded[0].Events()[0].Name != stackTraceEventName {
+		t.Fatal("want a stack event on every span with StackTraceAlways")
+	}
+}
@@ -0,0 +1,168 @@
+package otools
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rudiarta/otools/olog"
+	"github.com/rudiarta/otools/otrace"
+	otermetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var toolName string = "otools"
+
+// Telemetry holds the tracer, meter and logger providers of one telemetry
+// setup, so several differently configured setups can live in one process.
+// The package functions use a default Telemetry which also registers its
+// providers as the OTel globals, instances from NewTelemetry never touch
+// the globals.
+type Telemetry struct {
+	global bool
+
+	// traceMu guards the tracer state, InitTracer & ShutDownTraceProvider
+	// may run concurrently with StartTrace
+	traceMu          sync.RWMutex
+	traceHost        string
+	traceServiceName string
+	traceEnvironment string
+	isInitTrace      bool
+	tracerProvider   *tracesdk.TracerProvider
+	// traceConn, metricConn and logConn are swapped under their mutex and
+	// read without it by metric callbacks, which run under the SDK pipeline
+	// lock the mutexes are held around
+	traceConn atomic.Pointer[otrace.Conn]
+
+	// metricMu guards the meter state
+	metricMu     sync.RWMutex
+	isInitMetric bool
+	meter        otermetric.Meter
+	// meterGen count the meters instruments were moved to, see meterUpdate
+	meterGen      uint64
+	meterProvider *metric.MeterProvider
+	metricConn    atomic.Pointer[otrace.Conn]
+	promRegistry  *prometheus.Registry
+	instruments   []delegatingInstrument
+	registry      map[instrumentKey]*registeredInstrument
+	conflicts     []error
+	conflictKeys  map[conflictKey]struct{}
+	// cardinality is applied to instruments on InitMetrics
+	cardinality limiterConfig
+
+	// stackTraceMode, invalidParentFallback and redactor are set by
+	// TraceOptions or the setters, the package setters change the default
+	// Telemetry
+	stackTraceMode        atomic.Int32
+	invalidParentFallback atomic.Int32
+	redactor              atomic.Pointer[redactorSetting]
+
+	rejectedParentOnce sync.Once
+	rejectedParent     otermetric.Int64Counter
+
+	// logMu guards the log state
+	logMu          sync.RWMutex
+	loggerProvider *log.LoggerProvider
+	logConn        atomic.Pointer[otrace.Conn]
+	logger         *olog.Instance
+
+	// traceStats, metricStats & logStats count exports, see Health
+	traceStats  exportStats
+	metricStats exportStats
+	logStats    exportStats
+
+	exportDurationOnce sync.Once
+	exportDuration     otermetric.Float64Histogram
+
+	errorHandlerOnce sync.Once
+	lastError        atomic.Pointer[errorRecord]
+}
+
+var defaultTelemetry = &Telemetry{global: true}
+
+// NewTelemetry create an empty Telemetry, call InitTracer, InitMetrics and
+// InitLog on it like the package functions
+func NewTelemetry() *Telemetry {
+	return &Telemetry{logger: olog.New(nil)}
+}
+
+// Default get the Telemetry used by the package functions
+func Default() *Telemetry {
+	return defaultTelemetry
+}
+
+// ShutDown flush and shut down every provider of t
+func (t *Telemetry) ShutDown() error {
+	t.ShutDownTraceProvider()
+	t.ShutDownMeterProvider()
+	return t.ShutDownLogProvider()
+}
+
+// logInstance get the logger of t, the default Telemetry logs through the olog package
+func (t *Telemetry) logInstance() *olog.Instance {
+	if t.global {
+		return olog.Default()
+	}
+
+	t.logMu.RLock()
+	defer t.logMu.RUnlock()
+	if t.logger == nil {
+		return olog.Default()
+	}
+	return t.logger
+}
+
+// logErrors log every err of errs, e.g. errors collected while holding a lock
+func (t *Telemetry) logErrors(errs []error) {
+	for _, err := range errs {
+		t.E(context.Background(), err)
+	}
+}
+
+// E see olog.E
+func (t *Telemetry) E(ctx context.Context, message interface{}) {
+	t.logInstance().E(ctx, message)
+}
+
+// Ef see olog.Ef
+func (t *Telemetry) Ef(ctx context.Context, format string, i ...interface{}) {
+	t.logInstance().Ef(ctx, format, i...)
+}
+
+// I see olog.I
+func (t *Telemetry) I(ctx context.Context, message ...interface{}) {
+	t.logInstance().I(ctx, message...)
+}
+
+// If see olog.If
+func (t *Telemetry) If(ctx context.Context, format string, i ...interface{}) {
+	t.logInstance().If(ctx, format, i...)
+}
+
+// D see olog.D
+func (t *Telemetry) D(ctx context.Context, message ...interface{}) {
+	t.logInstance().D(ctx, message...)
+}
+
+// DF see olog.DF
+func (t *Telemetry) DF(ctx context.Context, format string, i ...interface{}) {
+	t.logInstance().DF(ctx, format, i...)
+}
+
+// W see olog.W
+func (t *Telemetry) W(ctx context.Context, message ...interface{}) {
+	t.logInstance().W(ctx, message...)
+}
+
+// Wf see olog.Wf
+func (t *Telemetry) Wf(ctx context.Context, format string, i ...interface{}) {
+	t.logInstance().Wf(ctx, format, i...)
+}
+
+// Panic see olog.Panic
+func (t *Telemetry) Panic(ctx context.Context, i ...interface{}) {
+	t.logInstance().Panic(ctx, i...)
+}
@@ -0,0 +1,111 @@
+package otools
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+)
+
+// run every fn concurrently n times, meant for go test -race
+func runConcurrently(n int, fns ...func()) {
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(fn func()) {
+				defer wg.Done()
+				fn()
+			}(fn)
+		}
+	}
+	wg.Wait()
+}
+
+func TestConcurrentInitShutdown(t *testing.T) {
+	chdirTemp(t)
+	tel := NewTelemetry()
+	counter := tel.CounterMetric("requests", "requests served", "1")
+	ctx := context.Background()
+
+	runConcurrently(5,
+		func() { _ = tel.InitTracer("", "svc", "local") },
+		func() { _ = tel.ShutDownTraceProvider() },
+		func() {
+			tr := tel.StartTrace(ctx, "work")
+			tr.SetTag("key", "value")
+			tr.Finish()
+		},
+		func() { _ = tel.InitMetrics("", "svc", "local", WithoutRuntimeMetrics(), WithoutProcessMetrics()) },
+		func() { _ = tel.InitMetrics("", "svc", "test") },
+		func() { _ = tel.ShutDownMeterProvider() },
+		func() { counter.Add(ctx, 1) },
+		func() { _ = tel.InitLog("", "svc", "local") },
+		func() { _ = tel.ShutDownLogProvider() },
+		func() { tel.I(ctx, "concurrent log") },
+	)
+
+	_ = tel.ShutDownTraceProvider()
+	_ = tel.ShutDownMeterProvider()
+	_ = tel.ShutDownLogProvider()
+}
+
+func TestConcurrentInitGlobalProvider(t *testing.T) {
+	chdirTemp(t)
+	tel := &Telemetry{global: true}
+	defer func() {
+		_ = tel.ShutDownTraceProvider()
+		_ = tel.ShutDownMeterProvider()
+		_ = tel.ShutDownLogProvider()
+	}()
+
+	runConcurrently(5,
+		func() { _ = tel.InitTracer("", "svc", "local") },
+		func() { _ = tel.InitMetrics("", "svc", "local", WithoutRuntimeMetrics(), WithoutProcessMetrics()) },
+		func() { _ = tel.InitLog("", "svc", "local") },
+	)
+
+	// the global is the provider kept by the last swap, not one shut down
+	tel.traceMu.RLock()
+	if otel.GetTracerProvider() != tel.tracerProvider {
+		t.Error("global tracer provider is not the current provider")
+	}
+	tel.traceMu.RUnlock()
+	tel.metricMu.RLock()
+	if otel.GetMeterProvider() != tel.meterProvider {
+		t.Error("global meter provider is not the current provider")
+	}
+	tel.metricMu.RUnlock()
+	tel.logMu.RLock()
+	if global.GetLoggerProvider() != tel.loggerProvider {
+		t.Error("global logger provider is not the current provider")
+	}
+	tel.logMu.RUnlock()
+}
+
+func TestInitMetricsTestEnvShutsDownProvider(t *testing.T) {
+	chdirTemp(t)
+	tel := NewTelemetry()
+	if err := tel.InitMetrics("", "svc", "local", WithoutRuntimeMetrics(), WithoutProcessMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	tel.metricMu.RLock()
+	old := tel.meterProvider
+	tel.metricMu.RUnlock()
+
+	if err := tel.InitMetrics("", "svc", "test"); err != nil {
+		t.Fatal(err)
+	}
+	tel.metricMu.RLock()
+	current := tel.meterProvider
+	tel.metricMu.RUnlock()
+	if current != nil {
+		t.Fatal("test environment kept the previous meter provider")
+	}
+	// a provider already shut down returns an error
+	if err := old.Shutdown(context.Background()); err == nil {
+		t.Fatal("previous meter provider was not shut down")
+	}
+}
@@ -2,108 +2,72 @@ package otools
 
 import (
 	"context"
-	"os"
-	"runtime/debug"
+	"fmt"
 	"strings"
 
-	"github.com/rudiarta/otools/olog"
 	"github.com/rudiarta/otools/otrace"
-	"github.com/rudiarta/otools/outils"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/noop"
 )
 
-var lpTracehost, lpTraceServiceName, lpTraceEnvironment string
-var isInitTrace bool = false
-var tp *tracesdk.TracerProvider
-var f *os.File
-var toolName string = "otools"
-
-func constructNewSpanContext(ctx context.Context, request trace.SpanContext) (spanContext trace.SpanContext, err error) {
-	var traceID trace.TraceID
-	traceID, err = trace.TraceIDFromHex(request.TraceID().String())
-	if err != nil {
-		olog.Ef(ctx, "error: %s", err.Error())
-		return spanContext, err
-	}
-	var spanID trace.SpanID
-	spanID, err = trace.SpanIDFromHex(request.SpanID().String())
-	if err != nil {
-		olog.Ef(ctx, "error: %s", err.Error())
-		return spanContext, err
-	}
-	var spanContextConfig trace.SpanContextConfig
-	spanContextConfig.TraceID = traceID
-	spanContextConfig.SpanID = spanID
-	spanContextConfig.TraceFlags = 01
-	spanContextConfig.Remote = false
-	spanContext = trace.NewSpanContext(spanContextConfig)
-	return spanContext, nil
-}
-
-func constructNewSpanContextWithString(ctx context.Context, request otrace.NewRequest) (spanContext trace.SpanContext, err error) {
-	var traceID trace.TraceID
-	traceID, err = trace.TraceIDFromHex(request.TraceID)
-	if err != nil {
-		olog.Ef(ctx, "error: %s", err.Error())
-		return spanContext, err
-	}
-	var spanID trace.SpanID
-	spanID, err = trace.SpanIDFromHex(request.SpanID)
-	if err != nil {
-		olog.Ef(ctx, "error: %s", err.Error())
-		return spanContext, err
-	}
-	var spanContextConfig trace.SpanContextConfig
-	spanContextConfig.TraceID = traceID
-	spanContextConfig.SpanID = spanID
-	spanContextConfig.TraceFlags = 01
-	spanContextConfig.Remote = false
-	spanContext = trace.NewSpanContext(spanContextConfig)
-	return spanContext, nil
-}
-
 // host for otel-collecter GRPC Ex: "localhost:30080"
 // serviceName Ex: "name_service"
 // environment Ex: "DEV"
-func getGrpcOtelTraceProvider(host, serviceName, environment string) (*tracesdk.TracerProvider, error) {
+// With otrace.WithBlock the provider is returned together with
+// otrace.ErrNotReady when the collector is not reachable in time.
+func (t *Telemetry) getGrpcOtelTraceProvider(host, serviceName, environment string, cfg traceConfig) (*tracesdk.TracerProvider, *otrace.Conn, error) {
 	ctx := context.Background()
-	var exp tracesdk.SpanExporter
-
-	conn := otrace.NewGrpcConn(ctx, host)
+	var (
+		exp     tracesdk.SpanExporter
+		conn    *otrace.Conn
+		connErr error
+		err     error
+	)
 
 	switch {
-	case strings.Contains(lpTraceEnvironment, "local"):
-		// Write telemetry data to a file.
-		var err error
-		f, err = os.OpenFile("traces.json", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	case strings.Contains(environment, "local"):
+		// Write telemetry data to a file, closed when the provider shuts down.
+		exp, err = otrace.NewFileSpanExporter("traces.json")
 		if err != nil {
-			olog.E(ctx, err)
+			return nil, nil, err
 		}
-		exp, _ = otrace.NewExporterTraceFile(f)
-	case strings.Contains(lpTraceEnvironment, "test"):
+	case strings.Contains(environment, "test"):
 		exp = tracetest.NewNoopExporter()
 	default:
-		exp, _ = otrace.NewExporterTraceGRPC(ctx, conn)
+		conn, connErr = otrace.Dial(ctx, host, cfg.dialOpts...)
+		if conn == nil {
+			return nil, nil, connErr
+		}
+		exp, err = otrace.NewExporterTraceGRPC(ctx, conn.ClientConn)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
 	}
 
 	res := otrace.NewResource(serviceName, environment)
 
 	// Register the trace exporter with a TracerProvider, using a batch
-	// span processor to aggregate spans before export.
-	bsp := tracesdk.NewBatchSpanProcessor(exp)
-	tracerProvider := tracesdk.NewTracerProvider(
+	// span processor to aggregate spans before export, after the custom
+	// processors of WithSpanProcessors.
+	providerOpts := []tracesdk.TracerProviderOption{
 		tracesdk.WithSampler(tracesdk.AlwaysSample()),
 		tracesdk.WithResource(res),
-		tracesdk.WithSpanProcessor(bsp),
-	)
+	}
+	for _, p := range cfg.processors {
+		providerOpts = append(providerOpts, tracesdk.WithSpanProcessor(p))
+	}
+	providerOpts = append(providerOpts, tracesdk.WithSpanProcessor(t.newSpanProcessor(exp, cfg)))
+	if cfg.spanMetrics {
+		providerOpts = append(providerOpts, tracesdk.WithSpanProcessor(t.newSpanMetricsProcessor(cfg.spanMetricsKeys)))
+	}
+	tracerProvider := tracesdk.NewTracerProvider(providerOpts...)
 
-	return tracerProvider, nil
+	return tracerProvider, conn, connErr
 }
 
 // GetTraceID func
@@ -129,160 +93,269 @@ func GetSpanID(ctx context.Context) string {
 }
 
 type tracerImpl struct {
-	ctx  context.Context
-	span trace.Span
-	tags map[string]interface{}
+	ctx        context.Context
+	span       trace.Span
+	tracer     trace.Tracer
+	stackTrace bool
+	// tel holds the stack trace mode and redactor of the span
+	tel *Telemetry
 }
 
+// Tracer wraps an OTel span, every method is safe to call when tracing is
+// not initialised, the span is a no-op span in that case.
 type Tracer interface {
 	Context() context.Context
 	SetError(err error)
+	// SetTag set a span attribute, ints, floats, bools and slices of them keep
+	// their type, maps and structs are flattened into "key.field" attributes
+	SetTag(key string, value interface{})
+	SetTags(tags map[string]interface{})
+	// AddEvent add a timestamped event with optional attributes
+	AddEvent(name string, tags ...map[string]interface{})
+	// SetStatus set span status, description is only kept for codes.Error
+	SetStatus(code codes.Code, description string)
+	SetName(name string)
+	// AddLink link this span to another span context, e.g. a message producer
+	AddLink(spanContext trace.SpanContext, tags ...map[string]interface{})
+	// StartChild start a new span as a child of this span
+	StartChild(operationName string, opts ...StartOption) Tracer
+	// RecordStackTrace add the current stack as a span event
+	RecordStackTrace()
+	// Span get the underlying OTel span
+	Span() trace.Span
+	TraceID() string
+	SpanID() string
 	Finish(additionalTags ...map[string]interface{})
 }
 
 // host for otel-collecter GRPC Ex: "localhost:30080"
 // serviceName Ex: "name_service"
 // environment Ex: "DEV"
-func InitTracer(host, serviceName, environment string) {
-	lpTracehost = host
-	lpTraceServiceName = serviceName
-	lpTraceEnvironment = environment
-	tp, _ = getGrpcOtelTraceProvider(lpTracehost, lpTraceServiceName, lpTraceEnvironment)
-
-	if !strings.Contains(lpTraceEnvironment, "test") {
-		otel.SetTracerProvider(tp)
+// Calling InitTracer again replaces the tracer provider, the previous one is
+// shut down after the new one is in place. See TraceOption for span metrics
+// and the collector connection. An invalid host is returned as error and
+// leaves the current provider in place.
+func InitTracer(host, serviceName, environment string, opts ...TraceOption) error {
+	return defaultTelemetry.InitTracer(host, serviceName, environment, opts...)
+}
+
+// InitTracer see otools.InitTracer
+func (t *Telemetry) InitTracer(host, serviceName, environment string, opts ...TraceOption) error {
+	t.setErrorHandler()
+	cfg := newTraceConfig(opts...)
+	provider, conn, err := t.getGrpcOtelTraceProvider(host, serviceName, environment, cfg)
+	if provider == nil {
+		return err
 	}
-	isInitTrace = true
+	t.applyTraceConfig(cfg)
+
+	t.traceMu.Lock()
+	oldProvider, oldConn := t.tracerProvider, t.traceConn.Swap(conn)
+	t.traceHost = host
+	t.traceServiceName = serviceName
+	t.traceEnvironment = environment
+	t.tracerProvider = provider
+	t.isInitTrace = true
+	// set while holding traceMu so a concurrent InitTracer can not leave the
+	// global on the provider it shuts down
+	if t.global && !strings.Contains(environment, "test") {
+		otel.SetTracerProvider(provider)
+	}
+	t.traceMu.Unlock()
+
+	t.shutdownTracerProvider(oldProvider, oldConn)
+	return err
 }
 
 func ShutDownTraceProvider() error {
-	if tp != nil {
-		if err := tp.Shutdown(context.Background()); err != nil {
-			olog.DF(context.Background(), "Error shutting down tracer provider: %v", err)
+	return defaultTelemetry.ShutDownTraceProvider()
+}
+
+// ShutDownTraceProvider see otools.ShutDownTraceProvider
+func (t *Telemetry) ShutDownTraceProvider() error {
+	t.traceMu.Lock()
+	provider, conn := t.tracerProvider, t.traceConn.Swap(nil)
+	t.tracerProvider = nil
+	t.isInitTrace = false
+	t.traceMu.Unlock()
+
+	t.shutdownTracerProvider(provider, conn)
+	return nil
+}
+
+func (t *Telemetry) shutdownTracerProvider(provider *tracesdk.TracerProvider, conn *otrace.Conn) {
+	if provider != nil {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.DF(context.Background(), "Error shutting down tracer provider: %v", err)
 		}
-		olog.D(context.Background(), "Shutting down tracer provider successfully")
+		t.D(context.Background(), "Shutting down tracer provider successfully")
 	}
-	return nil
+	t.closeConn(conn)
+}
+
+// StartTrace start a span as child of the span in ctx,
+// see StartOption for span kind, attributes, links, timestamp and new root
+func StartTrace(ctx context.Context, operationName string, opts ...StartOption) Tracer {
+	return defaultTelemetry.StartTrace(ctx, operationName, opts...)
 }
 
-func StartTrace(ctx context.Context, operationName string) Tracer {
+// StartTrace see otools.StartTrace
+func (t *Telemetry) StartTrace(ctx context.Context, operationName string, opts ...StartOption) Tracer {
+	return t.startTrace(ctx, t.getTracer(ctx), operationName, opts...)
+}
+
+// getTracer get tracer from the provider, or a no-op tracer when tracing is
+// not initialised or runs in test environment
+func (t *Telemetry) getTracer(ctx context.Context) trace.Tracer {
+	t.traceMu.RLock()
+	defer t.traceMu.RUnlock()
 
-	if lpTracehost == "" {
-		olog.I(ctx, "InitTracer first")
+	if t.traceHost == "" {
+		t.I(ctx, "InitTracer first")
 	}
 
-	if tp == nil {
-		olog.I(ctx, "InitTracer first")
+	if t.tracerProvider == nil {
+		t.I(ctx, "InitTracer first")
 	}
 
-	var tr trace.Tracer
 	switch {
-	case strings.Contains(lpTraceEnvironment, "test") || !isInitTrace:
-		tr = trace.NewNoopTracerProvider().Tracer(toolName)
+	case strings.Contains(t.traceEnvironment, "test") || !t.isInitTrace:
+		return noop.NewTracerProvider().Tracer(toolName)
 	default:
-		tr = tp.Tracer(toolName)
+		return t.tracerProvider.Tracer(toolName)
 	}
+}
 
-	ctx, span := tr.Start(ctx, operationName)
+func (t *Telemetry) startTrace(ctx context.Context, tr trace.Tracer, operationName string, opts ...StartOption) Tracer {
+	cfg := newStartConfig(opts...)
+	ctx, span := tr.Start(ctx, operationName, cfg.spanStartOptions(t.getRedactor())...)
 
 	return &tracerImpl{
-		ctx:  ctx,
-		span: span,
+		ctx:        ctx,
+		span:       span,
+		tracer:     tr,
+		stackTrace: cfg.stackTrace,
+		tel:        t,
 	}
 }
 
-// Start tracer that will use new ctx from context.Background
-func StartTracerWithContextBackground(parentCtx context.Context, operationName string) Tracer {
-	span := trace.SpanFromContext(parentCtx)
-	spanContext, err := constructNewSpanContext(parentCtx, span.SpanContext())
-	if err != nil {
-		olog.If(parentCtx, "ERROR: %s ", err.Error())
-	}
-	if ok := spanContext.IsValid(); !ok {
-		olog.If(parentCtx, "IS VALID? : %v ", ok)
-	}
+// Start tracer that will use new ctx from context.Background,
+// see StartDetachedTrace
+func StartTracerWithContextBackground(parentCtx context.Context, operationName string, opts ...StartOption) Tracer {
+	return defaultTelemetry.StartDetachedTrace(parentCtx, operationName, opts...)
+}
 
-	requestContext := context.Background()
-	requestContext = trace.ContextWithSpanContext(requestContext, spanContext)
+// StartTracerWithContextBackground see otools.StartTracerWithContextBackground
+func (t *Telemetry) StartTracerWithContextBackground(parentCtx context.Context, operationName string, opts ...StartOption) Tracer {
+	return t.StartDetachedTrace(parentCtx, operationName, opts...)
+}
 
-	return StartTrace(requestContext, operationName)
+// Start tracer that will use specific trace & span ID, span kind is server
+// unless WithSpanKind is given. Invalid IDs are logged and handled by the
+// InvalidParentFallback, see StartTracerWithRemoteParent
+func StartTracerWithTraceIDAndSpanID(ctx context.Context, operationName, TraceID, SpanID string, opts ...StartOption) Tracer {
+	return defaultTelemetry.StartTracerWithTraceIDAndSpanID(ctx, operationName, TraceID, SpanID, opts...)
 }
 
-// Start tracer that will use specific trace & span ID
-func StartTracerWithTraceIDAndSpanID(ctx context.Context, operationName, TraceID, SpanID string) Tracer {
-	var span trace.Span
-	spanContext, err := constructNewSpanContextWithString(ctx, otrace.NewRequest{
-		TraceID: TraceID,
-		SpanID:  SpanID,
-	})
-	if err != nil {
-		olog.If(ctx, "ERROR: %s ", err.Error())
-	}
-	if ok := spanContext.IsValid(); !ok {
-		olog.If(ctx, "IS VALID? : %v ", ok)
-	}
+// StartTracerWithTraceIDAndSpanID see otools.StartTracerWithTraceIDAndSpanID
+func (t *Telemetry) StartTracerWithTraceIDAndSpanID(ctx context.Context, operationName, TraceID, SpanID string, opts ...StartOption) Tracer {
+	tr, _ := t.StartTracerWithRemoteParent(ctx, operationName, TraceID, SpanID, opts...)
+	return tr
+}
 
-	requestContext := trace.ContextWithSpanContext(ctx, spanContext)
+// Context get active context
+func (t *tracerImpl) Context() context.Context {
+	return t.ctx
+}
 
-	if lpTracehost == "" {
-		olog.I(ctx, "InitTracer first")
+// SetError record err as an exception event and set the span status to error
+func (t *tracerImpl) SetError(err error) {
+	if err == nil {
+		return
 	}
 
-	if tp == nil {
-		olog.I(ctx, "InitTracer first")
-	}
+	t.tel.recordException(t.span, fmt.Sprintf("%T", err), err)
+}
 
-	var tr trace.Tracer
-	switch {
-	case strings.Contains(lpTraceEnvironment, "test") || !isInitTrace:
-		tr = noop.NewTracerProvider().Tracer(toolName)
-	default:
-		tr = tp.Tracer(toolName)
-	}
+// AddEvent add event to span
+func (t *tracerImpl) AddEvent(name string, tags ...map[string]interface{}) {
+	t.span.AddEvent(name, trace.WithAttributes(tagsAttributes(t.tel.getRedactor(), tags...)...))
+}
 
-	ctx, span = tr.Start(requestContext, operationName, trace.WithSpanKind(trace.SpanKindServer))
+// SetStatus set span status
+func (t *tracerImpl) SetStatus(code codes.Code, description string) {
+	t.span.SetStatus(code, t.tel.getRedactor().RedactString(description))
+}
 
-	return &tracerImpl{
-		ctx:  ctx,
-		span: span,
-	}
+// SetName rename span
+func (t *tracerImpl) SetName(name string) {
+	t.span.SetName(name)
 }
 
-// Context get active context
-func (t *tracerImpl) Context() context.Context {
-	return t.ctx
+// AddLink add link to other span context
+func (t *tracerImpl) AddLink(spanContext trace.SpanContext, tags ...map[string]interface{}) {
+	t.span.AddLink(trace.Link{
+		SpanContext: spanContext,
+		Attributes:  tagsAttributes(t.tel.getRedactor(), tags...),
+	})
 }
 
-// SetError set error in span
-// this function is still not implemented
-func (t *tracerImpl) SetError(err error) {
-	// SetErrorFromContext(t.ctx, err)
+// StartChild start child span from this span context
+func (t *tracerImpl) StartChild(operationName string, opts ...StartOption) Tracer {
+	return t.tel.startTrace(t.ctx, t.tracer, operationName, opts...)
 }
 
+// Span get underlying span
+func (t *tracerImpl) Span() trace.Span {
+	return t.span
+}
+
+// TraceID get trace id of span, empty when span is not valid
+func (t *tracerImpl) TraceID() string {
+	return GetTraceID(t.ctx)
+}
+
+// SpanID get span id of span, empty when span is not valid
+func (t *tracerImpl) SpanID() string {
+	return GetSpanID(t.ctx)
+}
+
+// Finish set tags and end the span, when used with defer a panic is recorded
+// on the span before it continues
 func (t *tracerImpl) Finish(tags ...map[string]interface{}) {
-	defer func() {
-		t.span.End(trace.WithStackTrace(true))
-	}()
-
-	// Debug trace set to default attibute
-	t.span.SetAttributes(
-		semconv.ExceptionTypeKey.String("DEBUG"),
-		semconv.ExceptionMessageKey.String("Stack Trace Information"),
-		semconv.ExceptionStacktraceKey.String(string(debug.Stack())),
-	)
+	for _, tag := range tags {
+		t.SetTags(tag)
+	}
 
-	if tags != nil && t.tags == nil {
-		t.tags = make(map[string]interface{})
+	// recover only works when Finish itself is the deferred function
+	if r := recover(); r != nil {
+		t.tel.recordPanic(t.span, r)
+		t.span.End()
+		panic(r)
 	}
 
-	for _, tag := range tags {
-		for k, v := range tag {
-			t.tags[k] = v
-		}
+	if t.stackTrace {
+		recordStackTrace(t.span)
+	} else {
+		t.tel.finishStackTrace(t.span)
 	}
+	t.span.End()
+}
+
+// RecordStackTrace add the current stack to the span as an event,
+// regardless of StackTraceMode
+func (t *tracerImpl) RecordStackTrace() {
+	recordStackTrace(t.span)
+}
+
+// SetTag set span attribute
+func (t *tracerImpl) SetTag(key string, value interface{}) {
+	t.span.SetAttributes(tagAttributes(t.tel.getRedactor(), key, value)...)
+}
 
-	for k, v := range t.tags {
-		var myKey = attribute.Key(k)
-		t.span.SetAttributes(myKey.String(outils.ToString(v)))
+// SetTags set span attributes
+func (t *tracerImpl) SetTags(tags map[string]interface{}) {
+	for k, v := range tags {
+		t.SetTag(k, v)
 	}
 }
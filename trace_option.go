@@ -0,0 +1,141 @@
+package otools
+
+import (
+	"time"
+
+	"github.com/rudiarta/otools/outils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartOption configure a span started by StartTrace
+type StartOption func(*startConfig)
+
+type startConfig struct {
+	kind       trace.SpanKind
+	attributes []attribute.KeyValue
+	tags       map[string]interface{}
+	timestamp  time.Time
+	links      []trace.Link
+	newRoot    bool
+	stackTrace bool
+
+	// only used by StartDetachedTrace
+	followsFrom bool
+	contextKeys []interface{}
+}
+
+func newStartConfig(opts ...StartOption) *startConfig {
+	cfg := &startConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// spanStartOptions get the options of the span, attributes and tags are
+// redacted with r
+func (c *startConfig) spanStartOptions(r *outils.Redactor) []trace.SpanStartOption {
+	var opts []trace.SpanStartOption
+	if c.kind != trace.SpanKindUnspecified {
+		opts = append(opts, trace.WithSpanKind(c.kind))
+	}
+	if len(c.attributes) > 0 || len(c.tags) > 0 {
+		attrs := append(r.RedactAttributes(c.attributes...), tagsAttributes(r, c.tags)...)
+		opts = append(opts, trace.WithAttributes(attrs...))
+	}
+	if !c.timestamp.IsZero() {
+		opts = append(opts, trace.WithTimestamp(c.timestamp))
+	}
+	if len(c.links) > 0 {
+		opts = append(opts, trace.WithLinks(c.links...))
+	}
+	if c.newRoot {
+		opts = append(opts, trace.WithNewRoot())
+	}
+	return opts
+}
+
+// WithSpanKind set span kind, e.g. trace.SpanKindClient for outbound calls or
+// trace.SpanKindConsumer for message consumers. Default is internal.
+func WithSpanKind(kind trace.SpanKind) StartOption {
+	return func(c *startConfig) {
+		c.kind = kind
+	}
+}
+
+// WithServerSpan is WithSpanKind(trace.SpanKindServer)
+func WithServerSpan() StartOption { return WithSpanKind(trace.SpanKindServer) }
+
+// WithClientSpan is WithSpanKind(trace.SpanKindClient)
+func WithClientSpan() StartOption { return WithSpanKind(trace.SpanKindClient) }
+
+// WithProducerSpan is WithSpanKind(trace.SpanKindProducer)
+func WithProducerSpan() StartOption { return WithSpanKind(trace.SpanKindProducer) }
+
+// WithConsumerSpan is WithSpanKind(trace.SpanKindConsumer)
+func WithConsumerSpan() StartOption { return WithSpanKind(trace.SpanKindConsumer) }
+
+// WithAttributes set initial span attributes, they are visible to samplers
+func WithAttributes(attrs ...attribute.KeyValue) StartOption {
+	return func(c *startConfig) {
+		c.attributes = append(c.attributes, attrs...)
+	}
+}
+
+// WithTags set initial span tags like Tracer.SetTags, they are visible to samplers
+func WithTags(tags map[string]interface{}) StartOption {
+	return func(c *startConfig) {
+		if c.tags == nil {
+			c.tags = make(map[string]interface{}, len(tags))
+		}
+		for k, v := range tags {
+			c.tags[k] = v
+		}
+	}
+}
+
+// WithTimestamp set explicit span start time
+func WithTimestamp(t time.Time) StartOption {
+	return func(c *startConfig) {
+		c.timestamp = t
+	}
+}
+
+// WithLinks link the span to other span contexts
+func WithLinks(links ...trace.Link) StartOption {
+	return func(c *startConfig) {
+		c.links = append(c.links, links...)
+	}
+}
+
+// WithNewRoot start a new trace, ignoring the span in ctx
+func WithNewRoot() StartOption {
+	return func(c *startConfig) {
+		c.newRoot = true
+	}
+}
+
+// WithStackTrace add a stack event to this span on Finish,
+// regardless of StackTraceMode
+func WithStackTrace() StartOption {
+	return func(c *startConfig) {
+		c.stackTrace = true
+	}
+}
+
+// WithFollowsFrom make StartDetachedTrace start a new trace linked to the
+// parent span instead of a child of it, use it for work that outlives the parent
+func WithFollowsFrom() StartOption {
+	return func(c *startConfig) {
+		c.followsFrom = true
+	}
+}
+
+// WithContextValues make StartDetachedTrace copy the values of keys from the
+// parent context, e.g. request ID or tenant keys
+func WithContextValues(keys ...interface{}) StartOption {
+	return func(c *startConfig) {
+		c.contextKeys = append(c.contextKeys, keys...)
+	}
+}
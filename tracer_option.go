@@ -0,0 +1,132 @@
+package otools
+
+import (
+	"github.com/rudiarta/otools/otrace"
+	"github.com/rudiarta/otools/outils"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// TraceOption configure InitTracer
+type TraceOption func(*traceConfig)
+
+type traceConfig struct {
+	spanMetrics     bool
+	spanMetricsKeys []string
+	dialOpts        []otrace.DialOption
+	batch           BatchConfig
+	simple          bool
+	processors      []tracesdk.SpanProcessor
+
+	// stackTraceMode, invalidParentFallback and redactor are nil when not
+	// given, InitTracer keeps the current setting then
+	stackTraceMode        *StackTraceMode
+	invalidParentFallback *InvalidParentFallback
+	redactor              *redactorSetting
+}
+
+// redactorSetting is the redactor of a Telemetry, it may be nil to disable
+// redaction
+type redactorSetting struct {
+	redactor *outils.Redactor
+}
+
+func newTraceConfig(opts ...TraceOption) traceConfig {
+	var cfg traceConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithSpanMetrics record RED metrics for every ended span, see
+// SpanDurationMetric and SpanErrorMetric. attributeKeys are span attributes
+// added to the metrics next to span name, kind and status, keep them low
+// cardinality, e.g. "http.route" but not "http.url".
+func WithSpanMetrics(attributeKeys ...string) TraceOption {
+	return func(c *traceConfig) {
+		c.spanMetrics = true
+		c.spanMetricsKeys = append(c.spanMetricsKeys, attributeKeys...)
+	}
+}
+
+// WithTraceDialOptions configure the collector connection, e.g.
+// otrace.WithBlock to wait for the collector or otrace.WithBuffer
+func WithTraceDialOptions(opts ...otrace.DialOption) TraceOption {
+	return func(c *traceConfig) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// WithTraceBatch tune the batch span processor, see BatchConfig
+func WithTraceBatch(batch BatchConfig) TraceOption {
+	return func(c *traceConfig) {
+		c.batch = batch
+	}
+}
+
+// WithSimpleSpanProcessor export every span synchronously when it ends
+// instead of in batches, for debugging only as it slows down every request
+func WithSimpleSpanProcessor() TraceOption {
+	return func(c *traceConfig) {
+		c.simple = true
+	}
+}
+
+// WithSpanProcessors register additional span processors, they run before
+// the processor exporting spans
+func WithSpanProcessors(processors ...tracesdk.SpanProcessor) TraceOption {
+	return func(c *traceConfig) {
+		c.processors = append(c.processors, processors...)
+	}
+}
+
+// WithStackTraceMode set when stacks are captured, see StackTraceMode and
+// SetStackTraceMode
+func WithStackTraceMode(mode StackTraceMode) TraceOption {
+	return func(c *traceConfig) {
+		c.stackTraceMode = &mode
+	}
+}
+
+// WithInvalidParentFallback set how spans are started for malformed incoming
+// IDs, see SetInvalidParentFallback
+func WithInvalidParentFallback(fallback InvalidParentFallback) TraceOption {
+	return func(c *traceConfig) {
+		c.invalidParentFallback = &fallback
+	}
+}
+
+// WithRedactor redact span tags, attributes, statuses and exception messages
+// with r instead of outils.GetRedactor, nil disables redaction
+func WithRedactor(r *outils.Redactor) TraceOption {
+	return func(c *traceConfig) {
+		c.redactor = &redactorSetting{redactor: r}
+	}
+}
+
+// applyTraceConfig store the settings given as TraceOption on t
+func (t *Telemetry) applyTraceConfig(cfg traceConfig) {
+	if cfg.stackTraceMode != nil {
+		t.SetStackTraceMode(*cfg.stackTraceMode)
+	}
+	if cfg.invalidParentFallback != nil {
+		t.SetInvalidParentFallback(*cfg.invalidParentFallback)
+	}
+	if cfg.redactor != nil {
+		t.redactor.Store(cfg.redactor)
+	}
+}
+
+// SetRedactor redact the spans of t with r instead of outils.GetRedactor, nil
+// disables redaction. The package functions use outils.SetRedactor.
+func (t *Telemetry) SetRedactor(r *outils.Redactor) {
+	t.redactor.Store(&redactorSetting{redactor: r})
+}
+
+// getRedactor get the redactor of t, outils.GetRedactor unless one is set
+func (t *Telemetry) getRedactor() *outils.Redactor {
+	if s := t.redactor.Load(); s != nil {
+		return s.redactor
+	}
+	return outils.GetRedactor()
+}